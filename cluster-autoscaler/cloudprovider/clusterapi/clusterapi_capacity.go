@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	gpuapis "k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+)
+
+const (
+	// capacityAnnotationPrefix is the common prefix for every
+	// scale-from-zero capacity annotation, e.g.
+	// capacity.cluster-autoscaler.kubernetes.io/<resource-name>.
+	capacityAnnotationPrefix = "capacity.cluster-autoscaler.kubernetes.io/"
+
+	// ephemeralStorageKey is the annotation declaring a node's ephemeral
+	// storage capacity.
+	ephemeralStorageKey = capacityAnnotationPrefix + "ephemeral-storage"
+
+	// gpuProductLabel mirrors the label the device plugin/kubelet stamps
+	// on a real GPU node (nvidia.com/gpu.product) so the simulated node
+	// looks the same to scheduling plugins that key off it.
+	gpuProductLabel = "nvidia.com/gpu.product"
+)
+
+// reservedCapacityAnnotationSuffixes are capacity.cluster-autoscaler.kubernetes.io/*
+// annotations with dedicated, non-generic-resource handling; they must not
+// be treated as arbitrary extended resources by parseExtendedResources.
+var reservedCapacityAnnotationSuffixes = map[string]bool{
+	"cpu":           true,
+	"memory":        true,
+	"labels":        true,
+	"taints":        true,
+	"gpu-count":     true,
+	"gpu-type":      true,
+	"instance-type": true,
+}
+
+// parseExtendedResources parses every capacity.cluster-autoscaler.kubernetes.io/<name>
+// annotation that isn't handled elsewhere (cpu, memory, labels, taints,
+// gpu-count, gpu-type, instance-type) into a resource quantity, so that
+// accelerators, hugepages and arbitrary device-plugin resources advertised
+// by real instances of this type are reflected on the synthesized node.
+func parseExtendedResources(annotations map[string]string) (corev1.ResourceList, error) {
+	resources := corev1.ResourceList{}
+
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, capacityAnnotationPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(key, capacityAnnotationPrefix)
+		if reservedCapacityAnnotationSuffixes[name] {
+			continue
+		}
+
+		qty, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for annotation %q: %v", value, key, err)
+		}
+
+		resources[corev1.ResourceName(name)] = qty
+	}
+
+	if qty, found := annotations[ephemeralStorageKey]; found {
+		parsed, err := resource.ParseQuantity(qty)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for annotation %q: %v", qty, ephemeralStorageKey, err)
+		}
+		resources[corev1.ResourceEphemeralStorage] = parsed
+	}
+
+	return resources, nil
+}
+
+// parseGPUResource determines the GPU resource name, quantity, and any
+// label that should be projected onto the synthesized node from the
+// gpu-count/gpu-type annotations. gpuTypeKey may be a bare product name
+// (assumed nvidia, e.g. "nvidia-tesla-t4") or a "<vendor-resource>/<product>"
+// pair for non-nvidia accelerators, e.g. "amd.com/gpu/mi100".
+func parseGPUResource(annotations map[string]string) (corev1.ResourceName, resource.Quantity, map[string]string, error) {
+	countStr, hasCount := annotations[gpuCountKey]
+	typeStr, hasType := annotations[gpuTypeKey]
+
+	if !hasCount && !hasType {
+		return gpuapis.ResourceNvidiaGPU, *resource.NewQuantity(0, resource.DecimalSI), nil, nil
+	}
+
+	var count int64
+	if hasCount {
+		parsed, err := strconv.ParseInt(countStr, 10, 64)
+		if err != nil {
+			return "", resource.Quantity{}, nil, fmt.Errorf("invalid value %q for annotation %q: %v", countStr, gpuCountKey, err)
+		}
+		count = parsed
+	}
+
+	resourceName := gpuapis.ResourceNvidiaGPU
+	labels := map[string]string{}
+
+	if hasType {
+		vendorResource, product := "nvidia.com/gpu", typeStr
+		if idx := strings.LastIndex(typeStr, "/"); idx >= 0 {
+			vendorResource, product = typeStr[:idx], typeStr[idx+1:]
+		}
+		resourceName = corev1.ResourceName(vendorResource)
+		labels[gpuProductLabel] = product
+	}
+
+	return resourceName, *resource.NewQuantity(count, resource.DecimalSI), labels, nil
+}
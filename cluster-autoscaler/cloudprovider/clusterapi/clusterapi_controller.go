@@ -0,0 +1,225 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/scale"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// machineController watches the Cluster API scalable resources
+// (MachineSet, MachineDeployment, MachinePool) and the Machine/Node
+// objects backing them, and exposes the results as cloudprovider
+// NodeGroups.
+type machineController struct {
+	kubeclient            kubernetes.Interface
+	managementClient      dynamic.Interface
+	managementScaleClient scale.ScalesGetter
+
+	machineResource           schema.GroupVersionResource
+	machineSetResource        schema.GroupVersionResource
+	machineDeploymentResource schema.GroupVersionResource
+	machinePoolResource       schema.GroupVersionResource
+
+	machineInformer cache.SharedIndexInformer
+	nodeInformer    cache.SharedIndexInformer
+
+	// recorder emits Events against the scalable resource a nodegroup
+	// wraps, so that scaling decisions are visible via e.g. `kubectl
+	// describe machinedeployment`.
+	recorder record.EventRecorder
+
+	// instanceTypes resolves the capacity to assume for a scaled-from-zero
+	// node from its declared instance type, when it has no explicit
+	// cpuKey/memoryKey annotations. It may be nil; read it through
+	// catalog(), which then falls back to the built-in tables.
+	instanceTypes *instanceTypeCatalog
+}
+
+// catalog returns the controller's instance type catalog, falling back to
+// the built-in tables if none was configured at startup.
+func (c *machineController) catalog() *instanceTypeCatalog {
+	if c.instanceTypes != nil {
+		return c.instanceTypes
+	}
+	return &instanceTypeCatalog{types: mergeBuiltinInstanceTypes()}
+}
+
+// nodeGroups returns a nodegroup for every MachineSet/MachineDeployment/
+// MachinePool in the watched namespace(s) that declares the min/max size
+// annotations.
+func (c *machineController) nodeGroups() ([]*nodegroup, error) {
+	scalableResources, err := c.listScalableResources()
+	if err != nil {
+		return nil, err
+	}
+
+	nodegroups := make([]*nodegroup, 0, len(scalableResources))
+	for _, r := range scalableResources {
+		ng, err := newNodegroupFromScalableResource(c, r)
+		if err != nil {
+			return nil, err
+		}
+		if ng == nil {
+			// Resource does not declare scaling bounds; not managed by the autoscaler.
+			continue
+		}
+		nodegroups = append(nodegroups, ng)
+	}
+
+	return nodegroups, nil
+}
+
+// nodeGroupForNode returns the nodegroup that owns the Machine backing the
+// given Node, or nil if the Node is not managed by Cluster API.
+func (c *machineController) nodeGroupForNode(node *corev1.Node) (*nodegroup, error) {
+	machine, err := c.findMachineByProviderID(normalizedProviderString(node.Spec.ProviderID))
+	if err != nil {
+		return nil, err
+	}
+
+	var r *unstructured.Unstructured
+	if machine != nil {
+		r, err = c.findScalableResourceForMachine(machine)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// MachinePool-backed nodes have no owning Machine; match the
+		// MachinePool directly by providerID instead.
+		r, err = c.findMachinePoolForProviderID(normalizedProviderString(node.Spec.ProviderID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r == nil {
+		return nil, nil
+	}
+
+	return newNodegroupFromScalableResource(c, r)
+}
+
+// findMachinePoolForProviderID returns the MachinePool whose status or
+// spec references the given providerID, or nil if none is found.
+func (c *machineController) findMachinePoolForProviderID(providerID string) (*unstructured.Unstructured, error) {
+	pools, err := c.managementClient.Resource(c.machinePoolResource).Namespace("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pools.Items {
+		pool := &pools.Items[i]
+		ids, err := newMachinePoolScalableResource(c, pool).providerIDs()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if normalizedProviderString(id) == providerID {
+				return pool, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// findMachineByProviderID returns the Machine object whose providerID
+// matches providerID, or nil if none is found.
+func (c *machineController) findMachineByProviderID(providerID string) (*unstructured.Unstructured, error) {
+	machines, err := c.managementClient.Resource(c.machineResource).Namespace("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range machines.Items {
+		m := &machines.Items[i]
+		id, _, _ := unstructured.NestedString(m.Object, "spec", "providerID")
+		if id != "" && normalizedProviderString(id) == providerID {
+			return m, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// findScalableResourceForMachine returns the MachineSet or
+// MachineDeployment that owns the given Machine.
+func (c *machineController) findScalableResourceForMachine(machine *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	for _, ref := range machine.GetOwnerReferences() {
+		if ref.Kind != machineSetKind {
+			continue
+		}
+		machineSet, err := c.managementClient.Resource(c.machineSetResource).Namespace(machine.GetNamespace()).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		// A MachineSet owned by a MachineDeployment is scaled through
+		// the MachineDeployment, so that is the scalable resource.
+		for _, msRef := range machineSet.GetOwnerReferences() {
+			if msRef.Kind == machineDeploymentKind {
+				return c.managementClient.Resource(c.machineDeploymentResource).Namespace(machine.GetNamespace()).Get(context.TODO(), msRef.Name, metav1.GetOptions{})
+			}
+		}
+		return machineSet, nil
+	}
+
+	return nil, nil
+}
+
+// markMachineForDeletion annotates the given Machine so that the Machine
+// API controllers know to prioritise it for deletion when the owning
+// MachineSet/MachineDeployment's replica count is subsequently decreased.
+func (c *machineController) markMachineForDeletion(machine *unstructured.Unstructured) error {
+	machine = machine.DeepCopy()
+
+	annotations := machine.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[machineDeleteAnnotationKey] = "true"
+	machine.SetAnnotations(annotations)
+
+	_, err := c.managementClient.Resource(c.machineResource).Namespace(machine.GetNamespace()).Update(context.TODO(), machine, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *machineController) listScalableResources() ([]*unstructured.Unstructured, error) {
+	var resources []*unstructured.Unstructured
+
+	for _, gvr := range []schema.GroupVersionResource{c.machineSetResource, c.machineDeploymentResource, c.machinePoolResource} {
+		list, err := c.managementClient.Resource(gvr).Namespace("").List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", gvr.Resource, err)
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	}
+
+	return resources, nil
+}
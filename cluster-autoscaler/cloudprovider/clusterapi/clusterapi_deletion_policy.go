@@ -0,0 +1,193 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// deletionPolicyAnnotationKey selects the order in which candidate
+	// Machines are marked for deletion when a nodegroup is scaled down.
+	deletionPolicyAnnotationKey = "cluster.x-k8s.io/deletion-policy"
+	// deletePriorityAnnotationKey carries a Machine's relative deletion
+	// priority when deletionPolicyPriority is in effect. Lower values are
+	// deleted first.
+	deletePriorityAnnotationKey = "cluster.x-k8s.io/delete-priority"
+
+	deletionPolicyRandom   = "Random"
+	deletionPolicyNewest   = "Newest"
+	deletionPolicyOldest   = "Oldest"
+	deletionPolicyPriority = "Priority"
+)
+
+// DeleteNodesOptions controls how DeleteNodesWithOptions selects which of
+// the candidate Machines to mark for deletion first. It is currently
+// unexported-empty and reserved for caller-supplied overrides; today the
+// policy is always read from the nodegroup's deletionPolicyAnnotationKey.
+type DeleteNodesOptions struct{}
+
+// deletionPolicy returns the configured deletion-policy for the nodegroup,
+// defaulting to Random when the annotation is absent or unrecognised.
+func (ng *nodegroup) deletionPolicy() string {
+	switch policy := ng.scalableResource.Annotations()[deletionPolicyAnnotationKey]; policy {
+	case deletionPolicyNewest, deletionPolicyOldest, deletionPolicyPriority:
+		return policy
+	default:
+		return deletionPolicyRandom
+	}
+}
+
+// orderMachinesForDeletion sorts machines according to the nodegroup's
+// deletion policy, returning a new slice; the input is left untouched.
+func orderMachinesForDeletion(policy string, machines []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	ordered := make([]*unstructured.Unstructured, len(machines))
+	copy(ordered, machines)
+
+	switch policy {
+	case deletionPolicyNewest:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].GetCreationTimestamp().Time.After(ordered[j].GetCreationTimestamp().Time)
+		})
+	case deletionPolicyOldest:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].GetCreationTimestamp().Time.Before(ordered[j].GetCreationTimestamp().Time)
+		})
+	case deletionPolicyPriority:
+		for _, m := range ordered {
+			if _, found := m.GetAnnotations()[deletePriorityAnnotationKey]; !found {
+				return nil, fmt.Errorf("deletion-policy %q requires %q to be set on all candidate machines, missing on %q", policy, deletePriorityAnnotationKey, m.GetName())
+			}
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return machineDeletePriority(ordered[i]) < machineDeletePriority(ordered[j])
+		})
+	default:
+		rand.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+	}
+
+	return ordered, nil
+}
+
+func machineDeletePriority(m *unstructured.Unstructured) int {
+	priority, err := strconv.Atoi(m.GetAnnotations()[deletePriorityAnnotationKey])
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// DeleteNodesWithOptions behaves like DeleteNodes but orders the candidate
+// Machines according to the nodegroup's cluster.x-k8s.io/deletion-policy
+// annotation before marking them for deletion.
+func (ng *nodegroup) DeleteNodesWithOptions(nodes []*corev1.Node, opts DeleteNodesOptions) error {
+	size, err := ng.TargetSize()
+	if err != nil {
+		return err
+	}
+
+	if size <= ng.MinSize() {
+		ng.recordEvent(corev1.EventTypeWarning, eventReasonScaleDownAborted, "Scale down aborted, already at min size %d", ng.MinSize())
+		return fmt.Errorf("min size reached, nodes will not be deleted")
+	}
+
+	machines := make([]*unstructured.Unstructured, 0, len(nodes))
+	for _, node := range nodes {
+		machine, err := ng.machineController.findMachineByProviderID(normalizedProviderString(node.Spec.ProviderID))
+		if err != nil {
+			return err
+		}
+
+		if machine == nil {
+			// A failed Machine that never obtained a providerID has no
+			// backing Node; the core autoscaler instead hands us a
+			// placeholder Node whose ProviderID is the synthetic ID
+			// machineInstance assigned it, so it can still be targeted for
+			// deletion.
+			if namespace, name, ok := isFailedMachineID(node.Spec.ProviderID); ok {
+				machine, err = ng.machineController.managementClient.Resource(ng.machineController.machineResource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if machine == nil {
+			return fmt.Errorf("unable to find machine for node %q", node.Name)
+		}
+
+		if !ownedBy(machine, ng.scalableResource.Name(), ng.scalableResource.Kind()) {
+			// Machines of a MachineDeployment are owned by its MachineSets.
+			owner, err := ng.machineController.findScalableResourceForMachine(machine)
+			if err != nil {
+				return err
+			}
+			if owner == nil || owner.GetKind() != ng.scalableResource.Kind() || owner.GetName() != ng.scalableResource.Name() {
+				return fmt.Errorf("node %q doesn't belong to node group %q", node.Name, ng.Id())
+			}
+		}
+
+		if !machine.GetDeletionTimestamp().IsZero() {
+			// Already being deleted.
+			continue
+		}
+
+		machines = append(machines, machine)
+	}
+
+	ordered, err := orderMachinesForDeletion(ng.deletionPolicy(), machines)
+	if err != nil {
+		return err
+	}
+
+	if len(ordered) == 0 {
+		return nil
+	}
+
+	// Check this before marking any machine for deletion: a negative new
+	// size is impossible, but scaling down to exactly 0 is valid (and
+	// required for the scale-from-zero MinSize()==0 case), so nothing
+	// here should prevent it.
+	newSize := size - len(ordered)
+	if newSize < 0 {
+		return fmt.Errorf("unable to delete nodes, node group size would drop below zero")
+	}
+
+	for _, machine := range ordered {
+		if err := ng.machineController.markMachineForDeletion(machine); err != nil {
+			return err
+		}
+		ng.recordEvent(corev1.EventTypeNormal, eventReasonMachineMarkedForDeletion, "Marked machine %s for deletion (policy: %s)", machine.GetName(), ng.deletionPolicy())
+	}
+
+	if err := ng.scalableResource.SetSize(int32(newSize)); err != nil {
+		return err
+	}
+
+	ng.recordEvent(corev1.EventTypeNormal, eventReasonScaledDown, "Scaled down from %d to %d (delta %d)", size, newSize, -len(ordered))
+	return nil
+}
@@ -0,0 +1,313 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+func newDeletionCandidateMachine(name string, created time.Time, annotations map[string]string) *unstructured.Unstructured {
+	m := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	m.SetNamespace(testNamespace)
+	m.SetName(name)
+	m.SetCreationTimestamp(metav1.NewTime(created))
+	m.SetAnnotations(annotations)
+	return m
+}
+
+func machineNames(machines []*unstructured.Unstructured) []string {
+	names := make([]string, 0, len(machines))
+	for _, m := range machines {
+		names = append(names, m.GetName())
+	}
+	return names
+}
+
+func TestOrderMachinesForDeletion(t *testing.T) {
+	now := time.Now()
+	oldest := newDeletionCandidateMachine("oldest", now.Add(-2*time.Hour), nil)
+	middle := newDeletionCandidateMachine("middle", now.Add(-1*time.Hour), nil)
+	newest := newDeletionCandidateMachine("newest", now, nil)
+	machines := []*unstructured.Unstructured{middle, newest, oldest}
+
+	t.Run("Oldest", func(t *testing.T) {
+		ordered, err := orderMachinesForDeletion(deletionPolicyOldest, machines)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"oldest", "middle", "newest"}
+		if got := machineNames(ordered); !equalStrings(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Newest", func(t *testing.T) {
+		ordered, err := orderMachinesForDeletion(deletionPolicyNewest, machines)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"newest", "middle", "oldest"}
+		if got := machineNames(ordered); !equalStrings(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		low := newDeletionCandidateMachine("low-priority", now, map[string]string{deletePriorityAnnotationKey: "10"})
+		high := newDeletionCandidateMachine("high-priority", now, map[string]string{deletePriorityAnnotationKey: "1"})
+
+		ordered, err := orderMachinesForDeletion(deletionPolicyPriority, []*unstructured.Unstructured{low, high})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"high-priority", "low-priority"}
+		if got := machineNames(ordered); !equalStrings(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Priority missing priority annotation", func(t *testing.T) {
+		high := newDeletionCandidateMachine("high-priority", now, map[string]string{deletePriorityAnnotationKey: "1"})
+		unset := newDeletionCandidateMachine("no-priority", now, nil)
+
+		_, err := orderMachinesForDeletion(deletionPolicyPriority, []*unstructured.Unstructured{high, unset})
+		if err == nil {
+			t.Fatal("expected an error because not every candidate machine has a delete-priority annotation")
+		}
+		if !strings.Contains(err.Error(), deletePriorityAnnotationKey) {
+			t.Errorf("expected error to mention %q, got %q", deletePriorityAnnotationKey, err.Error())
+		}
+	})
+
+	t.Run("Random preserves every candidate", func(t *testing.T) {
+		ordered, err := orderMachinesForDeletion(deletionPolicyRandom, machines)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"middle", "newest", "oldest"}
+		got := machineNames(ordered)
+		sortStrings(got)
+		sortStrings(want)
+		if !equalStrings(got, want) {
+			t.Errorf("expected the same set of machines %v, got %v", want, got)
+		}
+	})
+
+	t.Run("leaves the input slice untouched", func(t *testing.T) {
+		original := machineNames(machines)
+		if _, err := orderMachinesForDeletion(deletionPolicyOldest, machines); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := machineNames(machines); !equalStrings(got, original) {
+			t.Errorf("expected input slice order %v to be unchanged, got %v", original, got)
+		}
+	})
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortStrings(s []string) {
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && s[j-1] > s[j]; j-- {
+			s[j-1], s[j] = s[j], s[j-1]
+		}
+	}
+}
+
+func TestNodeGroupDeleteNodesWithDeletionPolicy(t *testing.T) {
+	test := func(t *testing.T, testConfig *testConfig) {
+		controller, stop := mustCreateTestController(t, testConfig)
+		defer stop()
+
+		recorder := record.NewFakeRecorder(10)
+		controller.recorder = recorder
+
+		nodegroups, err := controller.nodeGroups()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if l := len(nodegroups); l != 1 {
+			t.Fatalf("expected 1 nodegroup, got %d", l)
+		}
+		ng := nodegroups[0]
+
+		// Give the candidate machines a mix of delete-priority
+		// annotations so the Priority policy has to reorder them rather
+		// than mark them in the order DeleteNodes received them.
+		priorities := map[string]string{
+			testConfig.machines[0].GetName(): "20",
+			testConfig.machines[1].GetName(): "5",
+		}
+		for _, machine := range testConfig.machines[:2] {
+			m := machine.DeepCopy()
+			annotations := m.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[deletePriorityAnnotationKey] = priorities[m.GetName()]
+			m.SetAnnotations(annotations)
+			if err := updateResource(controller.managementClient, controller.machineInformer, controller.machineResource, m); err != nil {
+				t.Fatalf("unexpected error updating machine: %v", err)
+			}
+		}
+
+		if err := ng.DeleteNodes(testConfig.nodes[:2]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// The lower delete-priority value (machines[1], priority "5") must
+		// be marked before the higher one (machines[0], priority "20").
+		expectEvent(t, recorder, testConfig.machines[1].GetName())
+		expectEvent(t, recorder, testConfig.machines[0].GetName())
+		expectEvent(t, recorder, eventReasonScaledDown)
+	}
+
+	t.Run("MachineSet", func(t *testing.T) {
+		test(t, createMachineSetTestConfig(RandomString(6), RandomString(6), 10, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "1",
+			nodeGroupMaxSizeAnnotationKey: "10",
+			deletionPolicyAnnotationKey:   deletionPolicyPriority,
+		}))
+	})
+
+	t.Run("MachineDeployment", func(t *testing.T) {
+		test(t, createMachineDeploymentTestConfig(RandomString(6), RandomString(6), 10, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "1",
+			nodeGroupMaxSizeAnnotationKey: "10",
+			deletionPolicyAnnotationKey:   deletionPolicyPriority,
+		}))
+	})
+}
+
+func TestNodeGroupDeleteNodesPriorityPolicyRequiresPriorities(t *testing.T) {
+	test := func(t *testing.T, testConfig *testConfig) {
+		controller, stop := mustCreateTestController(t, testConfig)
+		defer stop()
+
+		nodegroups, err := controller.nodeGroups()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ng := nodegroups[0]
+
+		// None of the candidate machines carry a delete-priority
+		// annotation, so the Priority policy must refuse to pick an order.
+		err = ng.DeleteNodes(testConfig.nodes[:2])
+		if err == nil {
+			t.Fatal("expected an error because no machine carries a delete-priority annotation")
+		}
+		if !strings.Contains(err.Error(), deletePriorityAnnotationKey) {
+			t.Errorf("expected error to mention %q, got %q", deletePriorityAnnotationKey, err.Error())
+		}
+	}
+
+	t.Run("MachineSet", func(t *testing.T) {
+		test(t, createMachineSetTestConfig(RandomString(6), RandomString(6), 10, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "1",
+			nodeGroupMaxSizeAnnotationKey: "10",
+			deletionPolicyAnnotationKey:   deletionPolicyPriority,
+		}))
+	})
+
+	t.Run("MachineDeployment", func(t *testing.T) {
+		test(t, createMachineDeploymentTestConfig(RandomString(6), RandomString(6), 10, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "1",
+			nodeGroupMaxSizeAnnotationKey: "10",
+			deletionPolicyAnnotationKey:   deletionPolicyPriority,
+		}))
+	})
+}
+
+// TestNodeGroupDeleteNodesScalesToZero verifies that a MinSize()==0 group
+// can be scaled all the way down to 0 replicas via DeleteNodes - the
+// new-size guard must reject only a negative new size, not a size of 0.
+func TestNodeGroupDeleteNodesScalesToZero(t *testing.T) {
+	test := func(t *testing.T, testConfig *testConfig) {
+		controller, stop := mustCreateTestController(t, testConfig)
+		defer stop()
+
+		nodegroups, err := controller.nodeGroups()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if l := len(nodegroups); l != 1 {
+			t.Fatalf("expected 1 nodegroup, got %d", l)
+		}
+		ng := nodegroups[0]
+
+		if err := ng.DeleteNodes(testConfig.nodes); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		gvr, err := ng.scalableResource.GroupVersionResource()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		scalableResource, err := ng.machineController.managementScaleClient.Scales(testConfig.spec.namespace).
+			Get(context.TODO(), gvr.GroupResource(), ng.scalableResource.Name(), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if scalableResource.Spec.Replicas != 0 {
+			t.Errorf("expected 0, got %v", scalableResource.Spec.Replicas)
+		}
+
+		for _, machine := range testConfig.machines {
+			m, err := controller.managementClient.Resource(controller.machineResource).
+				Namespace(testConfig.spec.namespace).Get(context.TODO(), machine.GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, found := m.GetAnnotations()[machineDeleteAnnotationKey]; !found {
+				t.Errorf("expected annotation %q on machine %s", machineDeleteAnnotationKey, m.GetName())
+			}
+		}
+	}
+
+	t.Run("MachineSet", func(t *testing.T) {
+		test(t, createMachineSetTestConfig(RandomString(6), RandomString(6), 3, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "0",
+			nodeGroupMaxSizeAnnotationKey: "10",
+		}))
+	})
+
+	t.Run("MachineDeployment", func(t *testing.T) {
+		test(t, createMachineDeploymentTestConfig(RandomString(6), RandomString(6), 3, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "0",
+			nodeGroupMaxSizeAnnotationKey: "10",
+		}))
+	})
+}
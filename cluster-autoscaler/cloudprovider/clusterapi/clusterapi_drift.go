@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// driftEnabledAnnotationKey opts a nodegroup into drift detection: nodes
+// backed by a Machine whose template hash no longer matches the owning
+// MachineSet/MachineDeployment's current template are reported as
+// drifted, even though they're otherwise healthy.
+const driftEnabledAnnotationKey = "cluster.x-k8s.io/autoscaler-drift-enabled"
+
+const eventReasonMachineDrifted = "MachineDrifted"
+
+// Drifted reports whether node is backed by a Machine whose
+// template hash (taken over its infrastructureRef and
+// bootstrap.configRef, see templateHash) no longer matches the nodegroup's current template, along with a short
+// human readable reason. Drift detection is opt-in via
+// driftEnabledAnnotationKey.
+func (ng *nodegroup) Drifted(node *corev1.Node) (bool, string, error) {
+	if ng.scalableResource.Annotations()[driftEnabledAnnotationKey] != "true" {
+		return false, "", nil
+	}
+
+	machine, err := ng.machineController.findMachineByProviderID(normalizedProviderString(node.Spec.ProviderID))
+	if err != nil {
+		return false, "", err
+	}
+	if machine == nil {
+		return false, "", fmt.Errorf("unable to find machine for node %q", node.Name)
+	}
+
+	currentHash, err := templateHash(ng.scalableResource.UnstructuredResource())
+	if err != nil {
+		return false, "", err
+	}
+
+	machineHash, found, err := unstructured.NestedString(machine.Object, "metadata", "annotations", machineTemplateHashAnnotationKey)
+	if err != nil {
+		return false, "", err
+	}
+	if !found {
+		// The Machine was created before drift detection was enabled, or
+		// by tooling that doesn't stamp the hash; treat it as not drifted
+		// rather than flagging every pre-existing Machine.
+		return false, "", nil
+	}
+
+	if machineHash == currentHash {
+		return false, "", nil
+	}
+
+	reason := fmt.Sprintf("machine %s template hash %s does not match current %s template hash %s", machine.GetName(), machineHash, ng.scalableResource.Kind(), currentHash)
+
+	if ng.machineController.recorder != nil {
+		ng.machineController.recorder.Eventf(machine, corev1.EventTypeWarning, eventReasonMachineDrifted, reason)
+	}
+
+	return true, reason, nil
+}
+
+// machineTemplateHashAnnotationKey records, on each Machine created from a
+// template, the hash of that template at creation time so it can later be
+// compared against the owning resource's current template.
+const machineTemplateHashAnnotationKey = "cluster.x-k8s.io/template-hash"
+
+// templateHash computes a stable hash over the infrastructureRef and
+// bootstrap.configRef of a MachineSet/MachineDeployment's Machine
+// template, which change whenever the underlying AMI, image, or bootstrap
+// config is rolled forward.
+func templateHash(scalableResource *unstructured.Unstructured) (string, error) {
+	infraRef, _, err := unstructured.NestedMap(scalableResource.Object, "spec", "template", "spec", "infrastructureRef")
+	if err != nil {
+		return "", err
+	}
+	bootstrapRef, _, err := unstructured.NestedMap(scalableResource.Object, "spec", "template", "spec", "bootstrap", "configRef")
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%v", infraRef)
+	fmt.Fprintf(h, "%v", bootstrapRef)
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
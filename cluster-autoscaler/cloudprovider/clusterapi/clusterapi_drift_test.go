@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// stampMachineTemplateHash sets the machineTemplateHashAnnotationKey
+// annotation on the Machine backing node to hash, simulating what
+// tooling would have stamped on it at creation time.
+func stampMachineTemplateHash(controller *machineController, node *corev1.Node, hash string) error {
+	m, err := controller.findMachineByProviderID(normalizedProviderString(node.Spec.ProviderID))
+	if err != nil {
+		return err
+	}
+
+	annotations := m.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[machineTemplateHashAnnotationKey] = hash
+	m.SetAnnotations(annotations)
+
+	_, err = controller.managementClient.Resource(controller.machineResource).Namespace(m.GetNamespace()).Update(context.TODO(), m, metav1.UpdateOptions{})
+	return err
+}
+
+// TestNodeGroupDrift exercises Drifted() across a non-drifted Machine
+// (whose stamped template hash matches the nodegroup's current template
+// hash) and a drifted one (whose stamped hash is stale), flipping only
+// the stamped hash between the two assertions.
+func TestNodeGroupDrift(t *testing.T) {
+	annotations := map[string]string{
+		nodeGroupMinSizeAnnotationKey: "1",
+		nodeGroupMaxSizeAnnotationKey: "10",
+		driftEnabledAnnotationKey:     "true",
+	}
+	testConfig := createMachineSetTestConfig(RandomString(6), RandomString(6), 3, annotations)
+
+	controller, stop := mustCreateTestController(t, testConfig)
+	defer stop()
+
+	nodegroups, err := controller.nodeGroups()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l := len(nodegroups); l != 1 {
+		t.Fatalf("expected 1 nodegroup, got %d", l)
+	}
+	ng := nodegroups[0]
+
+	currentHash, err := templateHash(ng.scalableResource.UnstructuredResource())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	notDriftedNode := testConfig.nodes[0]
+	if err := stampMachineTemplateHash(controller, notDriftedNode, currentHash); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	drifted, reason, err := ng.Drifted(notDriftedNode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if drifted {
+		t.Errorf("expected node with matching template hash to not be drifted, got reason %q", reason)
+	}
+	if reason != "" {
+		t.Errorf("expected empty reason for a non-drifted node, got %q", reason)
+	}
+
+	driftedNode := testConfig.nodes[1]
+	if err := stampMachineTemplateHash(controller, driftedNode, "stale-hash-value"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	drifted, reason, err = ng.Drifted(driftedNode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !drifted {
+		t.Fatal("expected node with stale template hash to be drifted")
+	}
+	if reason == "" {
+		t.Error("expected a non-empty reason for a drifted node")
+	}
+}
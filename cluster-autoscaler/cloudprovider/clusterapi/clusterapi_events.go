@@ -0,0 +1,34 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+// Event reasons recorded against the scalable resource a nodegroup wraps.
+const (
+	eventReasonScaledUp                 = "ScaledUp"
+	eventReasonScaledDown               = "ScaledDown"
+	eventReasonScaleDownAborted         = "ScaleDownAborted"
+	eventReasonMachineMarkedForDeletion = "MachineMarkedForDeletion"
+)
+
+// recordEvent emits an Event against the nodegroup's underlying scalable
+// resource, if the controller was constructed with an EventRecorder.
+func (ng *nodegroup) recordEvent(eventtype, reason, messageFmt string, args ...interface{}) {
+	if ng.machineController.recorder == nil {
+		return
+	}
+	ng.machineController.recorder.Eventf(ng.scalableResource.UnstructuredResource(), eventtype, reason, messageFmt, args...)
+}
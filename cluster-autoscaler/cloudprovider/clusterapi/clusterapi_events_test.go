@@ -0,0 +1,125 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+)
+
+// expectEvent drains one event off recorder.Events and asserts it
+// contains substr, failing the test if no event arrived.
+func expectEvent(t *testing.T, recorder *record.FakeRecorder, substr string) {
+	t.Helper()
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, substr) {
+			t.Errorf("expected an event containing %q, got %q", substr, event)
+		}
+	default:
+		t.Errorf("expected an event containing %q, got none", substr)
+	}
+}
+
+func TestNodeGroupIncreaseSizeRecordsScaledUpEvent(t *testing.T) {
+	annotations := map[string]string{
+		nodeGroupMinSizeAnnotationKey: "1",
+		nodeGroupMaxSizeAnnotationKey: "10",
+	}
+	testConfig := createMachineSetTestConfig(RandomString(6), RandomString(6), 3, annotations)
+
+	controller, stop := mustCreateTestController(t, testConfig)
+	defer stop()
+
+	recorder := record.NewFakeRecorder(10)
+	controller.recorder = recorder
+
+	nodegroups, err := controller.nodeGroups()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l := len(nodegroups); l != 1 {
+		t.Fatalf("expected 1 nodegroup, got %d", l)
+	}
+
+	if err := nodegroups[0].IncreaseSize(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectEvent(t, recorder, eventReasonScaledUp)
+}
+
+func TestNodeGroupDeleteNodesRecordsEvents(t *testing.T) {
+	annotations := map[string]string{
+		nodeGroupMinSizeAnnotationKey: "1",
+		nodeGroupMaxSizeAnnotationKey: "10",
+	}
+	testConfig := createMachineSetTestConfig(RandomString(6), RandomString(6), 3, annotations)
+
+	controller, stop := mustCreateTestController(t, testConfig)
+	defer stop()
+
+	recorder := record.NewFakeRecorder(10)
+	controller.recorder = recorder
+
+	nodegroups, err := controller.nodeGroups()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l := len(nodegroups); l != 1 {
+		t.Fatalf("expected 1 nodegroup, got %d", l)
+	}
+
+	if err := nodegroups[0].DeleteNodes(testConfig.nodes[:1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectEvent(t, recorder, eventReasonMachineMarkedForDeletion)
+	expectEvent(t, recorder, eventReasonScaledDown)
+}
+
+func TestNodeGroupDeleteNodesRecordsScaleDownAbortedEvent(t *testing.T) {
+	annotations := map[string]string{
+		nodeGroupMinSizeAnnotationKey: "1",
+		nodeGroupMaxSizeAnnotationKey: "10",
+	}
+	testConfig := createMachineSetTestConfig(RandomString(6), RandomString(6), 1, annotations)
+
+	controller, stop := mustCreateTestController(t, testConfig)
+	defer stop()
+
+	recorder := record.NewFakeRecorder(10)
+	controller.recorder = recorder
+
+	nodegroups, err := controller.nodeGroups()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l := len(nodegroups); l != 1 {
+		t.Fatalf("expected 1 nodegroup, got %d", l)
+	}
+
+	// Already at min size: deleting the only node must be refused and
+	// recorded as an aborted scale-down, not a silent no-op.
+	if err := nodegroups[0].DeleteNodes(testConfig.nodes[:1]); err == nil {
+		t.Fatal("expected an error because the group is already at min size")
+	}
+
+	expectEvent(t, recorder, eventReasonScaleDownAborted)
+}
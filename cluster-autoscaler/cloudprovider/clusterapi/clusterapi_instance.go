@@ -0,0 +1,92 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// machineProvisioningTimeout bounds how long a Machine may sit in the
+// Provisioning phase with no providerID before it is reported to the
+// autoscaler as failed, rather than merely still-creating.
+const machineProvisioningTimeout = 15 * time.Minute
+
+const machineProvisioningPhase = "Provisioning"
+
+// machineInstance builds the cloudprovider.Instance reported for a single
+// Machine. A Machine with a providerID is Running. One without a
+// providerID is Creating and is reported under the synthetic
+// "failed-machine/<ns>_<name>" ID in place of a providerID, preserved for
+// backwards compatibility with callers keyed on Instance.Id; its ErrorInfo
+// is populated if it carries a failureMessage (permanently failed, with
+// failureReason as the error code) or has been Provisioning longer than
+// machineProvisioningTimeout (presumed stuck).
+func machineInstance(m *unstructured.Unstructured) cloudprovider.Instance {
+	if providerID, found, _ := unstructured.NestedString(m.Object, "spec", "providerID"); found && providerID != "" {
+		return cloudprovider.Instance{
+			Id:     providerID,
+			Status: &cloudprovider.InstanceStatus{State: cloudprovider.InstanceRunning},
+		}
+	}
+
+	id := fmt.Sprintf("%s%s_%s", failedMachinePrefix, m.GetNamespace(), m.GetName())
+	status := &cloudprovider.InstanceStatus{State: cloudprovider.InstanceCreating}
+
+	failureMessage, _, _ := unstructured.NestedString(m.Object, "status", "failureMessage")
+	failureReason, _, _ := unstructured.NestedString(m.Object, "status", "failureReason")
+	phase, _, _ := unstructured.NestedString(m.Object, "status", "phase")
+
+	switch {
+	case failureMessage != "":
+		status.ErrorInfo = &cloudprovider.InstanceErrorInfo{
+			ErrorClass:   cloudprovider.OtherErrorClass,
+			ErrorCode:    failureReason,
+			ErrorMessage: failureMessage,
+		}
+	case phase == machineProvisioningPhase && time.Since(m.GetCreationTimestamp().Time) > machineProvisioningTimeout:
+		status.ErrorInfo = &cloudprovider.InstanceErrorInfo{
+			ErrorClass:   cloudprovider.OtherErrorClass,
+			ErrorCode:    "ProvisioningTimeout",
+			ErrorMessage: fmt.Sprintf("machine %s has been provisioning for longer than %s", m.GetName(), machineProvisioningTimeout),
+		}
+	}
+
+	return cloudprovider.Instance{Id: id, Status: status}
+}
+
+// isFailedMachineID reports whether id is the synthetic ID machineInstance
+// assigns to a Machine with no providerID, and if so returns the
+// namespace/name it encodes.
+func isFailedMachineID(id string) (namespace, name string, ok bool) {
+	if len(id) <= len(failedMachinePrefix) || id[:len(failedMachinePrefix)] != failedMachinePrefix {
+		return "", "", false
+	}
+
+	rest := id[len(failedMachinePrefix):]
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '_' {
+			return rest[:i], rest[i+1:], true
+		}
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+func newProvisioningMachine(name string, created time.Time) *unstructured.Unstructured {
+	m := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	m.SetNamespace(testNamespace)
+	m.SetName(name)
+	m.SetCreationTimestamp(metav1.NewTime(created))
+	unstructured.SetNestedField(m.Object, machineProvisioningPhase, "status", "phase")
+	return m
+}
+
+func TestMachineInstanceStuckProvisioning(t *testing.T) {
+	m := newProvisioningMachine("stuck-machine", time.Now().Add(-(machineProvisioningTimeout + time.Minute)))
+
+	instance := machineInstance(m)
+
+	wantID := fmt.Sprintf("%s%s_%s", failedMachinePrefix, m.GetNamespace(), m.GetName())
+	if instance.Id != wantID {
+		t.Fatalf("expected id %q, got %q", wantID, instance.Id)
+	}
+	if instance.Status.State != cloudprovider.InstanceCreating {
+		t.Fatalf("expected state %v, got %v", cloudprovider.InstanceCreating, instance.Status.State)
+	}
+	if instance.Status.ErrorInfo == nil {
+		t.Fatal("expected ErrorInfo to be populated for a machine stuck provisioning")
+	}
+	if instance.Status.ErrorInfo.ErrorCode != "ProvisioningTimeout" {
+		t.Fatalf("expected error code %q, got %q", "ProvisioningTimeout", instance.Status.ErrorInfo.ErrorCode)
+	}
+}
+
+func TestMachineInstanceStillProvisioning(t *testing.T) {
+	m := newProvisioningMachine("new-machine", time.Now())
+
+	instance := machineInstance(m)
+
+	if instance.Status.State != cloudprovider.InstanceCreating {
+		t.Fatalf("expected state %v, got %v", cloudprovider.InstanceCreating, instance.Status.State)
+	}
+	if instance.Status.ErrorInfo != nil {
+		t.Fatalf("expected no ErrorInfo for a machine still within the provisioning timeout, got %+v", instance.Status.ErrorInfo)
+	}
+}
+
+func TestIsFailedMachineID(t *testing.T) {
+	m := newProvisioningMachine("some-machine", time.Now())
+	id := machineInstance(m).Id
+
+	namespace, name, ok := isFailedMachineID(id)
+	if !ok {
+		t.Fatalf("expected %q to be recognised as a failed machine id", id)
+	}
+	if namespace != m.GetNamespace() || name != m.GetName() {
+		t.Fatalf("expected %s/%s, got %s/%s", m.GetNamespace(), m.GetName(), namespace, name)
+	}
+
+	if _, _, ok := isFailedMachineID("provider-id-1234"); ok {
+		t.Fatal("expected a normal provider id to not be recognised as a failed machine id")
+	}
+}
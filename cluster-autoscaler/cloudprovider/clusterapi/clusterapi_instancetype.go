@@ -0,0 +1,221 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+
+	gpuapis "k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+)
+
+// instanceTypeAnnotationKey lets a MachineSet/MachineDeployment declare its
+// instance type directly, for node groups whose Machine template does not
+// carry a node.kubernetes.io/instance-type label.
+const instanceTypeAnnotationKey = "capacity.cluster-autoscaler.kubernetes.io/instance-type"
+
+// instanceTypeInfo describes the capacity the cluster-autoscaler should
+// assume for a scaled-from-zero node of a given instance type.
+type instanceTypeInfo struct {
+	VCPU                int64
+	MemoryMiB           int64
+	GPUCount            int64
+	GPUType             string
+	EphemeralStorageGiB int64
+}
+
+// gpuResource converts the catalog's GPUCount/GPUType into the same
+// (resourceName, quantity, labels) shape parseGPUResource derives from
+// annotations, mirroring its "<vendor-resource>/<product>" convention for
+// non-nvidia accelerators.
+func (i instanceTypeInfo) gpuResource() (corev1.ResourceName, resource.Quantity, map[string]string) {
+	resourceName := gpuapis.ResourceNvidiaGPU
+	var labels map[string]string
+
+	if i.GPUType != "" {
+		vendorResource, product := "nvidia.com/gpu", i.GPUType
+		if idx := strings.LastIndex(i.GPUType, "/"); idx >= 0 {
+			vendorResource, product = i.GPUType[:idx], i.GPUType[idx+1:]
+		}
+		resourceName = corev1.ResourceName(vendorResource)
+		labels = map[string]string{gpuProductLabel: product}
+	}
+
+	return resourceName, *resource.NewQuantity(i.GPUCount, resource.DecimalSI), labels
+}
+
+// instanceTypeCatalog resolves an instance type name (e.g. "m5.xlarge") to
+// its capacity, so node groups don't need to hand-maintain cpuKey/memoryKey
+// annotations for every well-known flavor.
+type instanceTypeCatalog struct {
+	types map[string]instanceTypeInfo
+}
+
+// newInstanceTypeCatalog builds the default catalog, merging the built-in
+// AWS/GCP/Azure/OpenStack tables with entries loaded from the given
+// ConfigMap, if one is configured. ConfigMap entries take precedence over
+// built-in entries of the same name.
+func newInstanceTypeCatalog(kubeclient kubernetes.Interface, namespace, name string) (*instanceTypeCatalog, error) {
+	catalog := &instanceTypeCatalog{types: mergeBuiltinInstanceTypes()}
+
+	if namespace == "" || name == "" {
+		return catalog, nil
+	}
+
+	cm, err := kubeclient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load instance type catalog ConfigMap %s/%s: %v", namespace, name, err)
+	}
+
+	for instanceType, spec := range cm.Data {
+		info, err := parseInstanceTypeInfo(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry for instance type %q in ConfigMap %s/%s: %v", instanceType, namespace, name, err)
+		}
+		catalog.types[instanceType] = info
+	}
+
+	return catalog, nil
+}
+
+// lookup returns the capacity registered for instanceType, if any.
+func (c *instanceTypeCatalog) lookup(instanceType string) (instanceTypeInfo, bool) {
+	if c == nil {
+		return instanceTypeInfo{}, false
+	}
+	info, found := c.types[instanceType]
+	return info, found
+}
+
+// parseInstanceTypeInfo parses a ConfigMap data entry of the form
+// "cpu=2,memory=8192,gpu-count=1,gpu-type=nvidia-tesla-t4,ephemeral-storage=100",
+// mirroring the comma-separated k=v style already used by labelsKey/taintsKey.
+func parseInstanceTypeInfo(spec string) (instanceTypeInfo, error) {
+	var info instanceTypeInfo
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return instanceTypeInfo{}, fmt.Errorf("invalid entry %q", pair)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+		switch key {
+		case "cpu":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return instanceTypeInfo{}, err
+			}
+			info.VCPU = v
+		case "memory":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return instanceTypeInfo{}, err
+			}
+			info.MemoryMiB = v
+		case "gpu-count":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return instanceTypeInfo{}, err
+			}
+			info.GPUCount = v
+		case "gpu-type":
+			info.GPUType = value
+		case "ephemeral-storage":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return instanceTypeInfo{}, err
+			}
+			info.EphemeralStorageGiB = v
+		default:
+			return instanceTypeInfo{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	return info, nil
+}
+
+// instanceTypeFor returns the instance type declared for a scalable
+// resource, preferring the explicit instanceTypeAnnotationKey annotation
+// and falling back to the node.kubernetes.io/instance-type label carried
+// by its Machine template.
+func instanceTypeFor(annotations map[string]string, u *unstructured.Unstructured) (string, bool) {
+	if instanceType, found := annotations[instanceTypeAnnotationKey]; found && instanceType != "" {
+		return instanceType, true
+	}
+
+	instanceType, found, _ := unstructured.NestedString(u.Object, "spec", "template", "metadata", "labels", corev1.LabelInstanceTypeStable)
+	return instanceType, found && instanceType != ""
+}
+
+func mergeBuiltinInstanceTypes() map[string]instanceTypeInfo {
+	merged := map[string]instanceTypeInfo{}
+	for _, builtin := range []map[string]instanceTypeInfo{awsInstanceTypes, gcpInstanceTypes, azureInstanceTypes, openstackInstanceTypes} {
+		for k, v := range builtin {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// awsInstanceTypes, gcpInstanceTypes, azureInstanceTypes and
+// openstackInstanceTypes seed the catalog with capacity for a handful of
+// commonly used flavors from each provider. The tables are not exhaustive;
+// anything missing can be added via the ConfigMap override, or by falling
+// back to the explicit cpuKey/memoryKey annotations.
+var (
+	awsInstanceTypes = map[string]instanceTypeInfo{
+		"m5.large":   {VCPU: 2, MemoryMiB: 8192},
+		"m5.xlarge":  {VCPU: 4, MemoryMiB: 16384},
+		"m5.2xlarge": {VCPU: 8, MemoryMiB: 32768},
+		"c5.xlarge":  {VCPU: 4, MemoryMiB: 8192},
+		"r5.xlarge":  {VCPU: 4, MemoryMiB: 32768},
+		"p3.2xlarge": {VCPU: 8, MemoryMiB: 62464, GPUCount: 1, GPUType: "nvidia-tesla-v100"},
+	}
+
+	gcpInstanceTypes = map[string]instanceTypeInfo{
+		"n1-standard-2": {VCPU: 2, MemoryMiB: 7680},
+		"n1-standard-4": {VCPU: 4, MemoryMiB: 15360},
+		"n1-standard-8": {VCPU: 8, MemoryMiB: 30720},
+		"n1-highmem-4":  {VCPU: 4, MemoryMiB: 26624},
+	}
+
+	azureInstanceTypes = map[string]instanceTypeInfo{
+		"Standard_D2s_v3": {VCPU: 2, MemoryMiB: 8192},
+		"Standard_D4s_v3": {VCPU: 4, MemoryMiB: 16384},
+		"Standard_D8s_v3": {VCPU: 8, MemoryMiB: 32768},
+	}
+
+	openstackInstanceTypes = map[string]instanceTypeInfo{
+		"m1.medium": {VCPU: 2, MemoryMiB: 4096},
+		"m1.large":  {VCPU: 4, MemoryMiB: 8192},
+		"m1.xlarge": {VCPU: 8, MemoryMiB: 16384},
+	}
+)
@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// machineDeploymentScalableResource adapts a MachineDeployment to the
+// scalableResource interface. Instances are enumerated by following the
+// MachineSets it owns down to their Machines, since a MachineDeployment
+// never owns Machines directly.
+type machineDeploymentScalableResource struct {
+	unstructuredScalableResource
+}
+
+func newMachineDeploymentScalableResource(controller *machineController, u *unstructured.Unstructured) *machineDeploymentScalableResource {
+	return &machineDeploymentScalableResource{
+		unstructuredScalableResource{controller: controller, Unstructured: *u},
+	}
+}
+
+func (r *machineDeploymentScalableResource) Replicas() (int32, bool, error) {
+	replicas, found, err := unstructured.NestedInt64(r.Object, "spec", "replicas")
+	if err != nil {
+		return 0, false, err
+	}
+	return int32(replicas), found, nil
+}
+
+func (r *machineDeploymentScalableResource) SetSize(nreplicas int32) error {
+	gvr, err := r.GroupVersionResource()
+	if err != nil {
+		return err
+	}
+
+	scale, err := r.controller.managementScaleClient.Scales(r.Namespace()).Get(context.TODO(), gvr.GroupResource(), r.Name(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	scale.Spec.Replicas = nreplicas
+
+	_, err = r.controller.managementScaleClient.Scales(r.Namespace()).Update(context.TODO(), gvr.GroupResource(), scale, metav1.UpdateOptions{})
+	return err
+}
+
+// Instances returns the cloudprovider.Instance for every Machine belonging
+// to a MachineSet owned by this MachineDeployment.
+func (r *machineDeploymentScalableResource) Instances() ([]cloudprovider.Instance, error) {
+	machineSets, err := r.controller.managementClient.Resource(r.controller.machineSetResource).Namespace(r.Namespace()).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []cloudprovider.Instance
+	for i := range machineSets.Items {
+		ms := &machineSets.Items[i]
+		if !ownedBy(ms, r.Name(), machineDeploymentKind) {
+			continue
+		}
+		msResource := newMachineSetScalableResource(r.controller, ms)
+		msInstances, err := msResource.Instances()
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, msInstances...)
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// machinePoolScalableResource adapts a MachinePool (exp.cluster.x-k8s.io)
+// to the scalableResource interface. Unlike MachineSet/MachineDeployment,
+// a MachinePool does not own Machine objects; instances are enumerated
+// from its own status/spec instead.
+type machinePoolScalableResource struct {
+	unstructuredScalableResource
+}
+
+func newMachinePoolScalableResource(controller *machineController, u *unstructured.Unstructured) *machinePoolScalableResource {
+	return &machinePoolScalableResource{
+		unstructuredScalableResource{controller: controller, Unstructured: *u},
+	}
+}
+
+func (r *machinePoolScalableResource) Replicas() (int32, bool, error) {
+	replicas, found, err := unstructured.NestedInt64(r.Object, "spec", "replicas")
+	if err != nil {
+		return 0, false, err
+	}
+	return int32(replicas), found, nil
+}
+
+func (r *machinePoolScalableResource) SetSize(nreplicas int32) error {
+	gvr, err := r.GroupVersionResource()
+	if err != nil {
+		return err
+	}
+
+	scale, err := r.controller.managementScaleClient.Scales(r.Namespace()).Get(context.TODO(), gvr.GroupResource(), r.Name(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	scale.Spec.Replicas = nreplicas
+
+	_, err = r.controller.managementScaleClient.Scales(r.Namespace()).Update(context.TODO(), gvr.GroupResource(), scale, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+
+	return unstructured.SetNestedField(r.Object, int64(nreplicas), "spec", "replicas")
+}
+
+// Instances returns the cloudprovider.Instance tracked by the MachinePool.
+// Unlike a MachineSet/MachineDeployment's Machines, a MachinePool's
+// individual replicas carry no per-instance failure detail, so every
+// instance is reported as Running.
+func (r *machinePoolScalableResource) Instances() ([]cloudprovider.Instance, error) {
+	ids, err := r.providerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]cloudprovider.Instance, 0, len(ids))
+	for _, id := range ids {
+		instances = append(instances, cloudprovider.Instance{
+			Id:     id,
+			Status: &cloudprovider.InstanceStatus{State: cloudprovider.InstanceRunning},
+		})
+	}
+
+	return instances, nil
+}
+
+// providerIDs returns the provider IDs tracked by the MachinePool,
+// taken from status.nodeRefs (which reflects Nodes that have actually
+// joined, and so carry a real spec.providerID) whenever it is non-empty;
+// only when status.nodeRefs is empty is spec.providerIDList used instead.
+// The two lists are not merged.
+func (r *machinePoolScalableResource) providerIDs() ([]string, error) {
+	nodeRefs, found, err := unstructured.NestedSlice(r.Object, "status", "nodeRefs")
+	if err != nil {
+		return nil, err
+	}
+	if found && len(nodeRefs) > 0 {
+		var providerIDs []string
+		for _, ref := range nodeRefs {
+			refMap, ok := ref.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, ok := refMap["name"].(string)
+			if !ok || name == "" {
+				continue
+			}
+			node, err := r.nodeByName(name)
+			if err != nil {
+				return nil, err
+			}
+			if node != nil && node.Spec.ProviderID != "" {
+				providerIDs = append(providerIDs, node.Spec.ProviderID)
+			}
+		}
+		return providerIDs, nil
+	}
+
+	providerIDs, _, err := unstructured.NestedStringSlice(r.Object, "spec", "providerIDList")
+	return providerIDs, err
+}
+
+// nodeByName returns the Node named name from the controller's node
+// informer, or nil if it isn't (yet) known.
+func (r *machinePoolScalableResource) nodeByName(name string) (*corev1.Node, error) {
+	for _, obj := range r.controller.nodeInformer.GetStore().List() {
+		node, ok := obj.(*corev1.Node)
+		if ok && node.Name == name {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
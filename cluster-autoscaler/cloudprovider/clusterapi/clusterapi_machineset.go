@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// machineSetScalableResource adapts a MachineSet to the scalableResource
+// interface.
+type machineSetScalableResource struct {
+	unstructuredScalableResource
+}
+
+func newMachineSetScalableResource(controller *machineController, u *unstructured.Unstructured) *machineSetScalableResource {
+	return &machineSetScalableResource{
+		unstructuredScalableResource{controller: controller, Unstructured: *u},
+	}
+}
+
+func (r *machineSetScalableResource) Replicas() (int32, bool, error) {
+	replicas, found, err := unstructured.NestedInt64(r.Object, "spec", "replicas")
+	if err != nil {
+		return 0, false, err
+	}
+	return int32(replicas), found, nil
+}
+
+func (r *machineSetScalableResource) SetSize(nreplicas int32) error {
+	gvr, err := r.GroupVersionResource()
+	if err != nil {
+		return err
+	}
+
+	scale, err := r.controller.managementScaleClient.Scales(r.Namespace()).Get(context.TODO(), gvr.GroupResource(), r.Name(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	scale.Spec.Replicas = nreplicas
+
+	_, err = r.controller.managementScaleClient.Scales(r.Namespace()).Update(context.TODO(), gvr.GroupResource(), scale, metav1.UpdateOptions{})
+	return err
+}
+
+// Instances returns the cloudprovider.Instance for every Machine owned by
+// this MachineSet, excluding those already marked for deletion.
+func (r *machineSetScalableResource) Instances() ([]cloudprovider.Instance, error) {
+	machines, err := r.controller.managementClient.Resource(r.controller.machineResource).Namespace(r.Namespace()).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []cloudprovider.Instance
+	for i := range machines.Items {
+		m := &machines.Items[i]
+		if !ownedBy(m, r.Name(), machineSetKind) {
+			continue
+		}
+		if !m.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		instances = append(instances, machineInstance(m))
+	}
+
+	return instances, nil
+}
+
+func ownedBy(obj *unstructured.Unstructured, ownerName, ownerKind string) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == ownerKind && ref.Name == ownerName {
+			return true
+		}
+	}
+	return false
+}
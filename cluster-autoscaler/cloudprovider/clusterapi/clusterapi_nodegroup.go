@@ -0,0 +1,364 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// nodegroup implements cloudprovider.NodeGroup on top of a Cluster API
+// MachineSet, MachineDeployment or MachinePool.
+type nodegroup struct {
+	machineController *machineController
+	scalableResource  scalableResource
+
+	minSize int
+	maxSize int
+}
+
+// newScalableResource wraps an unstructured MachineSet, MachineDeployment
+// or MachinePool with the concrete scalableResource implementation for
+// its kind.
+func newScalableResource(controller *machineController, u *unstructured.Unstructured) (scalableResource, error) {
+	switch u.GetKind() {
+	case machineSetKind:
+		return newMachineSetScalableResource(controller, u), nil
+	case machineDeploymentKind:
+		return newMachineDeploymentScalableResource(controller, u), nil
+	case machinePoolKind:
+		return newMachinePoolScalableResource(controller, u), nil
+	default:
+		return nil, fmt.Errorf("unsupported scalable resource kind %q", u.GetKind())
+	}
+}
+
+func newNodegroupFromScalableResource(controller *machineController, u *unstructured.Unstructured) (*nodegroup, error) {
+	r, err := newScalableResource(controller, u)
+	if err != nil {
+		return nil, err
+	}
+
+	minSize, minSizeFound := r.MinSize()
+	maxSize, maxSizeFound := r.MaxSize()
+
+	if !minSizeFound && !maxSizeFound {
+		// Not configured to be managed by the autoscaler.
+		return nil, nil
+	}
+
+	if minSize < 0 {
+		return nil, fmt.Errorf("%s: %d must not be negative", nodeGroupMinSizeAnnotationKey, minSize)
+	}
+
+	if maxSize < 0 {
+		return nil, fmt.Errorf("%s: %d must not be negative", nodeGroupMaxSizeAnnotationKey, maxSize)
+	}
+
+	if maxSize < minSize {
+		return nil, fmt.Errorf("max size %d must be greater than or equal to min size %d", maxSize, minSize)
+	}
+
+	return &nodegroup{
+		machineController: controller,
+		scalableResource:  r,
+		minSize:           minSize,
+		maxSize:           maxSize,
+	}, nil
+}
+
+// MinSize returns the minimum number of nodes the nodegroup is allowed to scale down to.
+func (ng *nodegroup) MinSize() int {
+	return ng.minSize
+}
+
+// MaxSize returns the maximum number of nodes the nodegroup is allowed to scale up to.
+func (ng *nodegroup) MaxSize() int {
+	return ng.maxSize
+}
+
+// TargetSize returns the current target size of the nodegroup, as recorded on the scalable resource's spec.
+func (ng *nodegroup) TargetSize() (int, error) {
+	replicas, found, err := ng.scalableResource.Replicas()
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, nil
+	}
+	return int(replicas), nil
+}
+
+// IncreaseSize increases the target size of the nodegroup by delta.
+func (ng *nodegroup) IncreaseSize(delta int) error {
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+
+	size, err := ng.TargetSize()
+	if err != nil {
+		return err
+	}
+
+	if size+delta > ng.MaxSize() {
+		return fmt.Errorf("size increase too large - desired:%d max:%d", size+delta, ng.MaxSize())
+	}
+
+	newSize := size + delta
+	if err := ng.scalableResource.SetSize(int32(newSize)); err != nil {
+		return err
+	}
+
+	ng.recordEvent(corev1.EventTypeNormal, eventReasonScaledUp, "Scaled up from %d to %d (delta %d)", size, newSize, delta)
+	return nil
+}
+
+// DecreaseTargetSize decreases the target size of the nodegroup by delta.
+// It is only permitted when the target size is greater than the number of
+// existing instances, i.e. when there are orphaned replicas that have not
+// yet converged into Machines.
+func (ng *nodegroup) DecreaseTargetSize(delta int) error {
+	if delta >= 0 {
+		return fmt.Errorf("size decrease must be negative")
+	}
+
+	size, err := ng.TargetSize()
+	if err != nil {
+		return err
+	}
+
+	nodes, err := ng.Nodes()
+	if err != nil {
+		return err
+	}
+
+	if size+delta < len(nodes) {
+		return fmt.Errorf("attempt to delete existing nodes targetSize:%d delta:%d existingNodes: %d", size, delta, len(nodes))
+	}
+
+	return ng.scalableResource.SetSize(int32(size + delta))
+}
+
+// DeleteNodes marks the Machines backing the given nodes for deletion and
+// decrements the scalable resource's replica count by the number of nodes
+// successfully marked.
+func (ng *nodegroup) DeleteNodes(nodes []*corev1.Node) error {
+	return ng.DeleteNodesWithOptions(nodes, DeleteNodesOptions{})
+}
+
+// Id returns a stable identifier for the nodegroup derived from its kind, namespace and name.
+func (ng *nodegroup) Id() string {
+	return path.Join(ng.scalableResource.Kind(), ng.scalableResource.Namespace(), ng.scalableResource.Name())
+}
+
+// Debug returns a human readable description of the nodegroup's current state.
+func (ng *nodegroup) Debug() string {
+	replicas, _ := ng.TargetSize()
+	return fmt.Sprintf(debugFormat, ng.Id(), ng.MinSize(), ng.MaxSize(), replicas)
+}
+
+// Nodes returns the instances backing this nodegroup, including those
+// still provisioning or permanently failed.
+func (ng *nodegroup) Nodes() ([]cloudprovider.Instance, error) {
+	return ng.scalableResource.Instances()
+}
+
+// Exist always returns true; the nodegroup always reflects a resource already present on the API server.
+func (ng *nodegroup) Exist() bool {
+	return true
+}
+
+// Create is not supported; nodegroups are created out of band by applying a MachineSet/MachineDeployment manifest.
+func (ng *nodegroup) Create() (cloudprovider.NodeGroup, error) {
+	return nil, cloudprovider.ErrAlreadyExist
+}
+
+// Delete is not supported; nodegroups are deleted out of band by removing the MachineSet/MachineDeployment manifest.
+func (ng *nodegroup) Delete() error {
+	return cloudprovider.ErrNotImplemented
+}
+
+// Autoprovisioned always returns false; the clusterapi provider does not support autoprovisioned node groups.
+func (ng *nodegroup) Autoprovisioned() bool {
+	return false
+}
+
+// TemplateNodeInfo returns a NodeInfo synthesized from the scalable
+// resource's scale-from-zero capacity annotations, for use when the
+// nodegroup has no live Nodes to derive capacity from directly.
+func (ng *nodegroup) TemplateNodeInfo() (*schedulernodeinfo.NodeInfo, error) {
+	annotations := ng.scalableResource.Annotations()
+
+	cpu, cpuFound := annotations[cpuKey]
+	memory, memoryFound := annotations[memoryKey]
+
+	// Explicit cpuKey/memoryKey annotations always win; an instance type
+	// catalog lookup only fills in whichever of the two is missing.
+	var catalogInfo instanceTypeInfo
+	if !cpuFound || !memoryFound {
+		instanceType, found := instanceTypeFor(annotations, ng.scalableResource.UnstructuredResource())
+		if !found {
+			return nil, cloudprovider.ErrNotImplemented
+		}
+		var ok bool
+		catalogInfo, ok = ng.machineController.catalog().lookup(instanceType)
+		if !ok {
+			return nil, fmt.Errorf("no capacity information available for instance type %q", instanceType)
+		}
+	}
+
+	var cpuQuantity resource.Quantity
+	if cpuFound {
+		var err error
+		cpuQuantity, err = resource.ParseQuantity(cpu)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for annotation %q: %v", cpu, cpuKey, err)
+		}
+	} else {
+		cpuQuantity = *resource.NewQuantity(catalogInfo.VCPU, resource.DecimalSI)
+	}
+
+	var memoryMiB int64
+	if memoryFound {
+		var err error
+		memoryMiB, err = strconv.ParseInt(memory, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for annotation %q: %v", memory, memoryKey, err)
+		}
+	} else {
+		memoryMiB = catalogInfo.MemoryMiB
+	}
+
+	gpuResourceName, gpuQuantity, gpuLabels, err := parseGPUResource(annotations)
+	if err != nil {
+		return nil, err
+	}
+	if gpuQuantity.IsZero() && catalogInfo.GPUCount > 0 {
+		gpuResourceName, gpuQuantity, gpuLabels = catalogInfo.gpuResource()
+	}
+
+	extendedResources, err := parseExtendedResources(annotations)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := extendedResources[corev1.ResourceEphemeralStorage]; !found && catalogInfo.EphemeralStorageGiB > 0 {
+		extendedResources[corev1.ResourceEphemeralStorage] = *resource.NewQuantity(catalogInfo.EphemeralStorageGiB*1024*1024*1024, resource.DecimalSI)
+	}
+
+	capacity := corev1.ResourceList{
+		corev1.ResourceCPU:    cpuQuantity,
+		corev1.ResourceMemory: *resource.NewQuantity(memoryMiB*1024*1024, resource.DecimalSI),
+		corev1.ResourcePods:   *resource.NewQuantity(250, resource.DecimalSI),
+		gpuResourceName:       gpuQuantity,
+	}
+	for name, qty := range extendedResources {
+		capacity[name] = qty
+	}
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("%s-asg-%d", ng.scalableResource.Name(), rand.Int63()),
+			Labels: map[string]string{},
+		},
+		Status: corev1.NodeStatus{
+			Capacity: capacity,
+		},
+	}
+	node.Status.Allocatable = node.Status.Capacity
+
+	node.Labels[corev1.LabelHostname] = node.Name
+	node.Labels[corev1.LabelOSStable] = "linux"
+	node.Labels["beta.kubernetes.io/os"] = "linux"
+	node.Labels[corev1.LabelArchStable] = "amd64"
+	node.Labels["beta.kubernetes.io/arch"] = "amd64"
+	for k, v := range gpuLabels {
+		node.Labels[k] = v
+	}
+
+	// Labels declared on the Machine template are propagated to the Nodes
+	// it creates, so project them onto the template node as well.
+	templateLabels, _, err := unstructured.NestedStringMap(ng.scalableResource.UnstructuredResource().Object, "spec", "template", "spec", "metadata", "labels")
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range templateLabels {
+		node.Labels[k] = v
+	}
+
+	// If a real Node already exists (e.g. the group scaled up once before
+	// being scaled back to zero), prefer the well-known labels it reports
+	// over our best-effort defaults.
+	if realNode := ng.firstExistingNode(); realNode != nil {
+		for _, key := range wellKnownNodeLabelKeys {
+			if value, ok := realNode.Labels[key]; ok {
+				node.Labels[key] = value
+			}
+		}
+	}
+
+	nodegroupLabels, err := parseLabelsAnnotation(annotations, labelsKey)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range nodegroupLabels {
+		node.Labels[k] = v
+	}
+
+	taints, err := parseTaintsAnnotation(annotations, taintsKey)
+	if err != nil {
+		return nil, err
+	}
+	node.Spec.Taints = taints
+
+	nodeInfo := schedulernodeinfo.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	return nodeInfo, nil
+}
+
+// wellKnownNodeLabelKeys lists the labels copied from a real Node backing
+// the nodegroup onto the synthetic template node, when one exists.
+var wellKnownNodeLabelKeys = []string{
+	corev1.LabelOSStable,
+	corev1.LabelArchStable,
+	"node.kubernetes.io/instance-type",
+}
+
+// firstExistingNode returns the first live Node backing the nodegroup, or
+// nil if the group currently has none (e.g. it has truly scaled to zero).
+func (ng *nodegroup) firstExistingNode() *corev1.Node {
+	node := ng.machineController.nodeInformer.GetStore().List()
+	for _, obj := range node {
+		n, ok := obj.(*corev1.Node)
+		if !ok {
+			continue
+		}
+		if owner, err := ng.machineController.nodeGroupForNode(n); err == nil && owner != nil && owner.Id() == ng.Id() {
+			return n
+		}
+	}
+	return nil
+}
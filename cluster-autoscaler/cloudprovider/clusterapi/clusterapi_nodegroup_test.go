@@ -41,13 +41,14 @@ const (
 
 func TestNodeGroupNewNodeGroupConstructor(t *testing.T) {
 	type testCase struct {
-		description string
-		annotations map[string]string
-		errors      bool
-		replicas    int32
-		minSize     int
-		maxSize     int
-		nodeCount   int
+		description         string
+		annotations         map[string]string
+		errors              bool
+		replicas            int32
+		minSize             int
+		maxSize             int
+		nodeCount           int
+		expectScaleFromZero bool
 	}
 
 	var testCases = []testCase{{
@@ -104,6 +105,28 @@ func TestNodeGroupNewNodeGroupConstructor(t *testing.T) {
 		replicas:  5,
 		nodeCount: 5,
 		errors:    false,
+	}, {
+		description: "no error: min=0, max=5, without scale-from-zero capacity annotations",
+		annotations: map[string]string{
+			nodeGroupMaxSizeAnnotationKey: "5",
+		},
+		minSize:             0,
+		maxSize:             5,
+		replicas:            0,
+		errors:              false,
+		expectScaleFromZero: false,
+	}, {
+		description: "no error: min=0, max=5, with scale-from-zero capacity annotations",
+		annotations: map[string]string{
+			nodeGroupMaxSizeAnnotationKey: "5",
+			cpuKey:                        "2",
+			memoryKey:                     "2048",
+		},
+		minSize:             0,
+		maxSize:             5,
+		replicas:            0,
+		errors:              false,
+		expectScaleFromZero: true,
 	}}
 
 	newNodeGroup := func(controller *machineController, testConfig *testConfig) (*nodegroup, error) {
@@ -189,6 +212,20 @@ func TestNodeGroupNewNodeGroupConstructor(t *testing.T) {
 			t.Errorf("expected %t, got %t", false, result)
 		}
 
+		if tc.minSize == 0 {
+			nodeInfo, err := ng.TemplateNodeInfo()
+			if tc.expectScaleFromZero {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if nodeInfo == nil {
+					t.Fatal("expected a synthesized NodeInfo when scale-from-zero capacity annotations are present")
+				}
+			} else if err != cloudprovider.ErrNotImplemented {
+				t.Errorf("expected %v, got %v", cloudprovider.ErrNotImplemented, err)
+			}
+		}
+
 		// We test ng.Nodes() in TestControllerNodeGroupsNodeCount
 	}
 
@@ -1036,6 +1073,69 @@ func TestNodeGroupWithFailedMachine(t *testing.T) {
 	})
 }
 
+func TestNodeGroupDeleteNodesFailedMachine(t *testing.T) {
+	test := func(t *testing.T, testConfig *testConfig) {
+		controller, stop := mustCreateTestController(t, testConfig)
+		defer stop()
+
+		// Simulate a failed machine that never obtained a providerID, and
+		// so has no backing Node of its own.
+		machine := testConfig.machines[3].DeepCopy()
+		unstructured.RemoveNestedField(machine.Object, "spec", "providerID")
+		unstructured.SetNestedField(machine.Object, "FailureMessage", "status", "failureMessage")
+
+		if err := updateResource(controller.managementClient, controller.machineInformer, controller.machineResource, machine); err != nil {
+			t.Fatalf("unexpected error updating machine, got %v", err)
+		}
+
+		nodegroups, err := controller.nodeGroups()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if l := len(nodegroups); l != 1 {
+			t.Fatalf("expected 1 nodegroup, got %d", l)
+		}
+		ng := nodegroups[0]
+
+		// The core autoscaler tracks the failed machine via the synthetic
+		// placeholder Node machineInstance assigned it rather than a real
+		// one, since no Node was ever created for it.
+		failedMachineID := fmt.Sprintf("%s%s_%s", failedMachinePrefix, machine.GetNamespace(), machine.GetName())
+		placeholder := &corev1.Node{
+			Spec: corev1.NodeSpec{ProviderID: failedMachineID},
+		}
+
+		if err := ng.DeleteNodes([]*corev1.Node{placeholder}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := wait.PollImmediate(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+			m, err := controller.managementClient.Resource(controller.machineResource).Namespace(machine.GetNamespace()).
+				Get(context.TODO(), machine.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return !m.GetDeletionTimestamp().IsZero(), nil
+		}); err != nil {
+			t.Fatalf("unexpected error waiting for the failed machine to be marked for deletion: %v", err)
+		}
+	}
+
+	t.Run("MachineSet", func(t *testing.T) {
+		test(t, createMachineSetTestConfig(RandomString(6), RandomString(6), 10, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "1",
+			nodeGroupMaxSizeAnnotationKey: "10",
+		}))
+	})
+
+	t.Run("MachineDeployment", func(t *testing.T) {
+		test(t, createMachineDeploymentTestConfig(RandomString(6), RandomString(6), 10, map[string]string{
+			nodeGroupMinSizeAnnotationKey: "1",
+			nodeGroupMaxSizeAnnotationKey: "10",
+		}))
+	})
+}
+
 func TestNodeGroupTemplateNodeInfo(t *testing.T) {
 	enableScaleAnnotations := map[string]string{
 		nodeGroupMinSizeAnnotationKey: "1",
@@ -1043,12 +1143,14 @@ func TestNodeGroupTemplateNodeInfo(t *testing.T) {
 	}
 
 	type testCaseConfig struct {
-		nodeLabels         map[string]string
-		nodegroupLabels    map[string]string
-		includeNodes       bool
-		expectedErr        error
-		expectedCapacity   map[corev1.ResourceName]int64
-		expectedNodeLabels map[string]string
+		nodeLabels          map[string]string
+		nodegroupLabels     map[string]string
+		includeNodes        bool
+		expectedErr         error
+		expectedErrContains string
+		expectedCapacity    map[corev1.ResourceName]int64
+		expectedNodeLabels  map[string]string
+		expectedTaints      []corev1.Taint
 	}
 
 	testCases := []struct {
@@ -1148,6 +1250,149 @@ func TestNodeGroupTemplateNodeInfo(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "When the NodeGroup declares an instance type instead of explicit cpu/memory",
+			nodeGroupAnnotations: map[string]string{
+				instanceTypeAnnotationKey: "m5.large",
+			},
+			config: testCaseConfig{
+				expectedErr: nil,
+				expectedCapacity: map[corev1.ResourceName]int64{
+					corev1.ResourceCPU:        2,
+					corev1.ResourceMemory:     8192 * 1024 * 1024,
+					corev1.ResourcePods:       250,
+					gpuapis.ResourceNvidiaGPU: 0,
+				},
+				expectedNodeLabels: map[string]string{
+					"kubernetes.io/os":        "linux",
+					"beta.kubernetes.io/os":   "linux",
+					"kubernetes.io/arch":      "amd64",
+					"beta.kubernetes.io/arch": "amd64",
+				},
+			},
+		},
+		{
+			name: "When the NodeGroup declares an instance type and an explicit memory annotation, the annotation wins for memory only",
+			nodeGroupAnnotations: map[string]string{
+				instanceTypeAnnotationKey: "m5.large",
+				memoryKey:                 "4096",
+			},
+			config: testCaseConfig{
+				expectedErr: nil,
+				expectedCapacity: map[corev1.ResourceName]int64{
+					corev1.ResourceCPU:        2,
+					corev1.ResourceMemory:     4096 * 1024 * 1024,
+					corev1.ResourcePods:       250,
+					gpuapis.ResourceNvidiaGPU: 0,
+				},
+				expectedNodeLabels: map[string]string{
+					"kubernetes.io/os":        "linux",
+					"beta.kubernetes.io/os":   "linux",
+					"kubernetes.io/arch":      "amd64",
+					"beta.kubernetes.io/arch": "amd64",
+				},
+			},
+		},
+		{
+			name: "When the NodeGroup declares taints via annotation",
+			nodeGroupAnnotations: map[string]string{
+				memoryKey: "2048",
+				cpuKey:    "2",
+				taintsKey: "dedicated=experimental:PreferNoSchedule,special=true:NoSchedule",
+			},
+			config: testCaseConfig{
+				expectedErr: nil,
+				expectedCapacity: map[corev1.ResourceName]int64{
+					corev1.ResourceCPU:        2,
+					corev1.ResourceMemory:     2048 * 1024 * 1024,
+					corev1.ResourcePods:       250,
+					gpuapis.ResourceNvidiaGPU: 0,
+				},
+				expectedNodeLabels: map[string]string{
+					"kubernetes.io/os":        "linux",
+					"beta.kubernetes.io/os":   "linux",
+					"kubernetes.io/arch":      "amd64",
+					"beta.kubernetes.io/arch": "amd64",
+				},
+				expectedTaints: []corev1.Taint{
+					{Key: "dedicated", Value: "experimental", Effect: corev1.TaintEffectPreferNoSchedule},
+					{Key: "special", Value: "true", Effect: corev1.TaintEffectNoSchedule},
+				},
+			},
+		},
+		{
+			name: "When the NodeGroup declares a malformed taints annotation",
+			nodeGroupAnnotations: map[string]string{
+				memoryKey: "2048",
+				cpuKey:    "2",
+				taintsKey: "not-a-valid-taint-entry",
+			},
+			config: testCaseConfig{
+				expectedErrContains: "invalid taint entry",
+			},
+		},
+		{
+			name: "When the NodeGroup declares a malformed labels annotation",
+			nodeGroupAnnotations: map[string]string{
+				memoryKey: "2048",
+				cpuKey:    "2",
+				labelsKey: "not-a-valid-label-pair",
+			},
+			config: testCaseConfig{
+				expectedErrContains: "invalid label pair",
+			},
+		},
+		{
+			name: "When the NodeGroup declares a non-nvidia GPU vendor",
+			nodeGroupAnnotations: map[string]string{
+				memoryKey:   "2048",
+				cpuKey:      "2",
+				gpuCountKey: "2",
+				gpuTypeKey:  "amd.com/gpu/mi100",
+			},
+			config: testCaseConfig{
+				expectedErr: nil,
+				expectedCapacity: map[corev1.ResourceName]int64{
+					corev1.ResourceCPU:                  2,
+					corev1.ResourceMemory:               2048 * 1024 * 1024,
+					corev1.ResourcePods:                 250,
+					corev1.ResourceName("amd.com/gpu"):  2,
+				},
+				expectedNodeLabels: map[string]string{
+					"kubernetes.io/os":        "linux",
+					"beta.kubernetes.io/os":   "linux",
+					"kubernetes.io/arch":      "amd64",
+					"beta.kubernetes.io/arch": "amd64",
+					gpuProductLabel:           "mi100",
+				},
+			},
+		},
+		{
+			name: "When the NodeGroup declares hugepages-2Mi and ephemeral-storage",
+			nodeGroupAnnotations: map[string]string{
+				memoryKey: "2048",
+				cpuKey:    "2",
+				capacityAnnotationPrefix + "hugepages-2Mi": "256Mi",
+				ephemeralStorageKey:                        "20Gi",
+			},
+			config: testCaseConfig{
+				expectedErr: nil,
+				expectedCapacity: map[corev1.ResourceName]int64{
+					corev1.ResourceCPU:                    2,
+					corev1.ResourceMemory:                 2048 * 1024 * 1024,
+					corev1.ResourcePods:                   250,
+					gpuapis.ResourceNvidiaGPU:              0,
+					corev1.ResourceName("hugepages-2Mi"):  256 * 1024 * 1024,
+					corev1.ResourceEphemeralStorage:       20 * 1024 * 1024 * 1024,
+				},
+				expectedNodeLabels: map[string]string{
+					"kubernetes.io/os":        "linux",
+					"beta.kubernetes.io/os":   "linux",
+					"kubernetes.io/arch":      "amd64",
+					"beta.kubernetes.io/arch": "amd64",
+				},
+			},
+		},
 	}
 
 	test := func(t *testing.T, testConfig *testConfig, config testCaseConfig) {
@@ -1185,6 +1430,12 @@ func TestNodeGroupTemplateNodeInfo(t *testing.T) {
 			}
 			return
 		}
+		if config.expectedErrContains != "" {
+			if err == nil || !strings.Contains(err.Error(), config.expectedErrContains) {
+				t.Fatalf("expected error containing %q, but got: %v", config.expectedErrContains, err)
+			}
+			return
+		}
 
 		nodeAllocatable := nodeInfo.Node().Status.Allocatable
 		nodeCapacity := nodeInfo.Node().Status.Capacity
@@ -1214,6 +1465,19 @@ func TestNodeGroupTemplateNodeInfo(t *testing.T) {
 				}
 			}
 		}
+
+		gotTaints := nodeInfo.Node().Spec.Taints
+		if len(gotTaints) != len(config.expectedTaints) {
+			t.Errorf("Expected %d taints, got %d: %+v", len(config.expectedTaints), len(gotTaints), gotTaints)
+		}
+		for i, expected := range config.expectedTaints {
+			if i >= len(gotTaints) {
+				break
+			}
+			if gotTaints[i] != expected {
+				t.Errorf("Expected taint %d to be %+v, got %+v", i, expected, gotTaints[i])
+			}
+		}
 	}
 
 	for _, tc := range testCases {
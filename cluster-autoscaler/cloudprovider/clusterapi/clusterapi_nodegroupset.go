@@ -0,0 +1,52 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"k8s.io/autoscaler/cluster-autoscaler/processors/nodegroupset"
+)
+
+// authoritativeLabelPrefixes lists label prefixes the clusterapi provider
+// always treats as authoritative-from-annotation. A scale-from-zero
+// template built from the labelsKey annotation may not yet carry a label
+// the kubelet only adds once a real Node joins (e.g. node-role labels
+// applied by an in-cluster controller), or vice versa; that alone must
+// not make an otherwise-identical node group look dissimilar.
+var authoritativeLabelPrefixes = []string{
+	"node-role.kubernetes.io/",
+	"capacity.spot",
+}
+
+// NodeInfoComparatorConfig returns the nodegroupset.NodeInfoComparatorConfig
+// the clusterapi provider registers with its NodeGroupSetProcessor: the
+// package defaults, extended with the label prefixes this provider itself
+// manages via the labelsKey/taintsKey annotations.
+func NodeInfoComparatorConfig() nodegroupset.NodeInfoComparatorConfig {
+	config := nodegroupset.DefaultNodeInfoComparatorConfig()
+	config.AuthoritativeLabelPrefixes = append(config.AuthoritativeLabelPrefixes, authoritativeLabelPrefixes...)
+	return config
+}
+
+// NewNodeGroupSetProcessor returns the nodegroupset.NodeGroupSetProcessor
+// the clusterapi provider should register, built from
+// NodeInfoComparatorConfig so that balancing recognizes the labels/taints
+// this provider synthesizes from labelsKey/taintsKey annotations as
+// authoritative, instead of rejecting an otherwise-identical scale-from-zero
+// group over a label the kubelet hasn't applied yet.
+func NewNodeGroupSetProcessor() nodegroupset.NodeGroupSetProcessor {
+	return nodegroupset.NewNodeGroupSetProcessor(NodeInfoComparatorConfig())
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"k8s.io/autoscaler/cluster-autoscaler/processors/nodegroupset"
+)
+
+func buildNodeInfo(name string, labels map[string]string) *schedulernodeinfo.NodeInfo {
+	capacity := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewQuantity(4, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(16*1024*1024*1024, resource.BinarySI),
+		corev1.ResourcePods:   *resource.NewQuantity(110, resource.DecimalSI),
+	}
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status:     corev1.NodeStatus{Capacity: capacity, Allocatable: capacity},
+	}
+
+	nodeInfo := schedulernodeinfo.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	return nodeInfo
+}
+
+// TestNewNodeGroupSetProcessorTreatsProviderLabelsAsAuthoritative verifies
+// that the processor returned by NewNodeGroupSetProcessor is actually built
+// from NodeInfoComparatorConfig, by checking that a node-role label - one
+// of the prefixes clusterapi itself adds on top of the package defaults -
+// present on only one side does not make two otherwise identical node
+// groups look dissimilar.
+func TestNewNodeGroupSetProcessorTreatsProviderLabelsAsAuthoritative(t *testing.T) {
+	processor, ok := NewNodeGroupSetProcessor().(*nodegroupset.BalancingNodeGroupSetProcessor)
+	if !ok {
+		t.Fatalf("expected a *nodegroupset.BalancingNodeGroupSetProcessor, got %T", NewNodeGroupSetProcessor())
+	}
+
+	n1 := buildNodeInfo("n1", map[string]string{corev1.LabelArchStable: "amd64"})
+	n2 := buildNodeInfo("n2", map[string]string{
+		corev1.LabelArchStable:            "amd64",
+		"node-role.kubernetes.io/worker": "true",
+	})
+
+	if result := processor.Comparator(n1, n2); !result {
+		t.Error("expected a one-sided node-role label to not make the groups dissimilar")
+	}
+
+	n2.Node().Labels["unrelated-label"] = "only-on-n2"
+	if result := processor.Comparator(n1, n2); result {
+		t.Error("expected a one-sided label outside any authoritative prefix to still make the groups dissimilar")
+	}
+}
+
+func TestNodeInfoComparatorConfigExtendsPackageDefaults(t *testing.T) {
+	config := NodeInfoComparatorConfig()
+	defaults := nodegroupset.DefaultNodeInfoComparatorConfig()
+
+	if len(config.AuthoritativeLabelPrefixes) != len(defaults.AuthoritativeLabelPrefixes)+len(authoritativeLabelPrefixes) {
+		t.Fatalf("expected the clusterapi prefixes to be appended to the package defaults, got %v", config.AuthoritativeLabelPrefixes)
+	}
+	for _, prefix := range authoritativeLabelPrefixes {
+		found := false
+		for _, p := range config.AuthoritativeLabelPrefixes {
+			if p == prefix {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected %q to be included in AuthoritativeLabelPrefixes", prefix)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"fmt"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// scaleUpSetAnnotationKey groups MachineDeployments/MachineSets that must
+// be scaled up together. AtomicIncreaseSize on any member increases every
+// member of the set by the same delta, rolling back on the first failure;
+// IncreaseSize scales only the nodegroup it is called on.
+const scaleUpSetAnnotationKey = "cluster.x-k8s.io/scale-up-set"
+
+// nodeGroupSet is a collection of nodegroups that scale atomically: a
+// single AtomicIncreaseSize call adds the full delta to every member; delta
+// is not split among members, and a member whose new size would exceed
+// its max size fails the whole call. On any failure the updates already
+// applied are reverted; errors from reverting are not reported.
+type nodeGroupSet struct {
+	name    string
+	members []*nodegroup
+}
+
+// appliedScaleUpdate records a successfully applied single-member update,
+// so it can be undone if a later member in the same set fails.
+type appliedScaleUpdate struct {
+	member  *nodegroup
+	oldSize int
+}
+
+// nodeGroupSetsFor returns the set ng belongs to, or nil if ng does not
+// declare a scale-up-set annotation.
+func (c *machineController) nodeGroupSetsFor(ng *nodegroup) (*nodeGroupSet, error) {
+	name, ok := ng.scalableResource.Annotations()[scaleUpSetAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	all, err := c.nodeGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &nodeGroupSet{name: name}
+	for _, other := range all {
+		if other.scalableResource.Annotations()[scaleUpSetAnnotationKey] == name {
+			set.members = append(set.members, other)
+		}
+	}
+
+	return set, nil
+}
+
+// AtomicIncreaseSize implements cloudprovider.NodeGroup. When ng belongs
+// to a scale-up set, every member of the set is scaled by delta; if any
+// member update fails, every update already applied in this call is
+// rolled back and the failure is returned. When ng does not belong to a
+// set, AtomicIncreaseSize is not supported.
+func (ng *nodegroup) AtomicIncreaseSize(delta int) error {
+	set, err := ng.machineController.nodeGroupSetsFor(ng)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		return cloudprovider.ErrNotImplemented
+	}
+
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+
+	var applied []appliedScaleUpdate
+
+	for _, member := range set.members {
+		size, err := member.TargetSize()
+		if err != nil {
+			rollbackScaleUpSet(applied)
+			return fmt.Errorf("scale-up set %q rolled back: %v", set.name, err)
+		}
+
+		newSize := size + delta
+		if newSize > member.MaxSize() {
+			rollbackScaleUpSet(applied)
+			return fmt.Errorf("scale-up set %q rolled back: size increase too large for %q - desired:%d max:%d", set.name, member.Id(), newSize, member.MaxSize())
+		}
+
+		if err := member.scalableResource.SetSize(int32(newSize)); err != nil {
+			rollbackScaleUpSet(applied)
+			return fmt.Errorf("scale-up set %q rolled back: failed to scale %q: %v", set.name, member.Id(), err)
+		}
+
+		applied = append(applied, appliedScaleUpdate{member: member, oldSize: size})
+	}
+
+	return nil
+}
+
+func rollbackScaleUpSet(applied []appliedScaleUpdate) {
+	for _, a := range applied {
+		_ = a.member.scalableResource.SetSize(int32(a.oldSize))
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	scalefake "k8s.io/client-go/scale/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+// TestScaleUpSetAtomicIncreaseSizeRollsBackOnFailure verifies the
+// all-or-nothing behavior AtomicIncreaseSize promises: when one member of
+// a scale-up set fails to update, every member update already applied in
+// the same call - including ones that individually succeeded - is rolled
+// back.
+func TestScaleUpSetAtomicIncreaseSizeRollsBackOnFailure(t *testing.T) {
+	namespace := RandomString(6)
+	annotations := map[string]string{
+		nodeGroupMinSizeAnnotationKey: "1",
+		nodeGroupMaxSizeAnnotationKey: "10",
+		scaleUpSetAnnotationKey:       "my-scale-up-set",
+	}
+
+	testConfig0 := createMachineDeploymentTestConfig(namespace, RandomString(6), 3, annotations)
+	testConfig1 := createMachineDeploymentTestConfig(namespace, RandomString(6), 3, annotations)
+
+	controller, stop := mustCreateTestController(t, testConfig0, testConfig1)
+	defer stop()
+
+	scaleClient, ok := controller.managementScaleClient.(*scalefake.FakeScaleClient)
+	if !ok {
+		t.Fatalf("expected the test controller's scale client to be a *scalefake.FakeScaleClient, got %T", controller.managementScaleClient)
+	}
+
+	failingName := testConfig1.spec.machineDeploymentName
+	scaleClient.PrependReactor("update", "machinedeployments", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		update, ok := action.(clientgotesting.UpdateAction)
+		if !ok {
+			return false, nil, nil
+		}
+		scale, ok := update.GetObject().(*autoscalingv1.Scale)
+		if !ok || scale.Name != failingName {
+			return false, nil, nil
+		}
+		return true, nil, fmt.Errorf("injected failure scaling %q", failingName)
+	})
+
+	nodegroups, err := controller.nodeGroups()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l := len(nodegroups); l != 2 {
+		t.Fatalf("expected 2 nodegroups, got %d", l)
+	}
+
+	var ng0 *nodegroup
+	for _, ng := range nodegroups {
+		if ng.scalableResource.Name() == testConfig0.spec.machineDeploymentName {
+			ng0 = ng
+		}
+	}
+	if ng0 == nil {
+		t.Fatal("could not find the nodegroup for the first MachineDeployment")
+	}
+
+	if err := ng0.AtomicIncreaseSize(2); err == nil {
+		t.Fatal("expected an error because the second member's update was injected to fail")
+	}
+
+	// ng0's update individually succeeded before ng1's failed - it must
+	// still have been rolled back to its original size.
+	gvr, err := ng0.scalableResource.GroupVersionResource()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	scale, err := controller.managementScaleClient.Scales(namespace).Get(context.TODO(), gvr.GroupResource(), ng0.scalableResource.Name(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if scale.Spec.Replicas != 3 {
+		t.Errorf("expected rollback to 3 replicas, got %v", scale.Spec.Replicas)
+	}
+}
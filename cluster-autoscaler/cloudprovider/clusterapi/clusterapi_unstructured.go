@@ -0,0 +1,207 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+const (
+	machineSetKind        = "MachineSet"
+	machineDeploymentKind = "MachineDeployment"
+	machinePoolKind       = "MachinePool"
+	machineKind           = "Machine"
+
+	nodeGroupMinSizeAnnotationKey = "cluster.x-k8s.io/cluster-api-autoscaler-node-group-min-size"
+	nodeGroupMaxSizeAnnotationKey = "cluster.x-k8s.io/cluster-api-autoscaler-node-group-max-size"
+
+	machineDeleteAnnotationKey = "cluster.x-k8s.io/delete-machine"
+
+	// memoryKey is the annotation used on a MachineSet/MachineDeployment to
+	// declare the amount of memory, in MiB, that a scaled-from-zero node will
+	// report.
+	memoryKey = "capacity.cluster-autoscaler.kubernetes.io/memory"
+	// cpuKey is the annotation used on a MachineSet/MachineDeployment to
+	// declare the number of CPUs that a scaled-from-zero node will report.
+	cpuKey = "capacity.cluster-autoscaler.kubernetes.io/cpu"
+	// gpuCountKey is the annotation used to declare the number of GPUs that a
+	// scaled-from-zero node will report.
+	gpuCountKey = "capacity.cluster-autoscaler.kubernetes.io/gpu-count"
+	// gpuTypeKey is the annotation used to declare the GPU type that a
+	// scaled-from-zero node will report.
+	gpuTypeKey = "capacity.cluster-autoscaler.kubernetes.io/gpu-type"
+	// labelsKey is the annotation used to declare a comma separated list of
+	// k=v pairs to project onto a scaled-from-zero node.
+	labelsKey = "capacity.cluster-autoscaler.kubernetes.io/labels"
+	// taintsKey is the annotation used to declare a comma separated list of
+	// k=v:Effect entries to project onto a scaled-from-zero node.
+	taintsKey = "capacity.cluster-autoscaler.kubernetes.io/taints"
+
+	failedMachinePrefix = "failed-machine/"
+
+	debugFormat = "%s (min: %d, max: %d, replicas: %d)"
+)
+
+// scalableResource is the interface used to abstract over the different
+// Cluster API resources (MachineSet, MachineDeployment, MachinePool) that
+// can be scaled by the autoscaler.
+type scalableResource interface {
+	Name() string
+	Namespace() string
+	Kind() string
+	UnstructuredResource() *unstructured.Unstructured
+	GroupVersionResource() (schema.GroupVersionResource, error)
+	Annotations() map[string]string
+	MinSize() (int, bool)
+	MaxSize() (int, bool)
+	Replicas() (int32, bool, error)
+	SetSize(nreplicas int32) error
+	// Instances returns the cloudprovider.Instance backing each Machine (or
+	// MachinePool replica) owned by this resource, including those still
+	// provisioning or permanently failed.
+	Instances() ([]cloudprovider.Instance, error)
+}
+
+// unstructuredScalableResource carries the fields and behaviour shared by
+// every scalableResource implementation. Concrete types embed it and add
+// the behaviour that differs between MachineSet, MachineDeployment and
+// MachinePool.
+type unstructuredScalableResource struct {
+	controller *machineController
+	unstructured.Unstructured
+}
+
+func (r unstructuredScalableResource) Name() string {
+	return r.GetName()
+}
+
+func (r unstructuredScalableResource) Namespace() string {
+	return r.GetNamespace()
+}
+
+func (r unstructuredScalableResource) Kind() string {
+	return r.GetKind()
+}
+
+func (r unstructuredScalableResource) UnstructuredResource() *unstructured.Unstructured {
+	return &r.Unstructured
+}
+
+func (r unstructuredScalableResource) Annotations() map[string]string {
+	return r.GetAnnotations()
+}
+
+func (r unstructuredScalableResource) GroupVersionResource() (schema.GroupVersionResource, error) {
+	gvk := r.GroupVersionKind()
+	return schema.GroupVersionResource{
+		Group:    gvk.Group,
+		Version:  gvk.Version,
+		Resource: strings.ToLower(gvk.Kind) + "s",
+	}, nil
+}
+
+func (r unstructuredScalableResource) MinSize() (int, bool) {
+	return parseScalingBounds(r.Annotations(), nodeGroupMinSizeAnnotationKey)
+}
+
+func (r unstructuredScalableResource) MaxSize() (int, bool) {
+	return parseScalingBounds(r.Annotations(), nodeGroupMaxSizeAnnotationKey)
+}
+
+func parseScalingBounds(annotations map[string]string, key string) (int, bool) {
+	val, found := annotations[key]
+	if !found {
+		return 0, false
+	}
+
+	size, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, true
+	}
+
+	return size, true
+}
+
+// normalizedProviderString strips any "<scheme>://" style prefix from a
+// providerID so values recorded by the Machine API can be compared against
+// the providerID reported on the corresponding Node.
+func normalizedProviderString(s string) string {
+	if i := strings.Index(s, "://"); i != -1 {
+		return s[i+len("://"):]
+	}
+	return s
+}
+
+func parseLabelsAnnotation(annotations map[string]string, key string) (map[string]string, error) {
+	val, found := annotations[key]
+	if !found || val == "" {
+		return nil, nil
+	}
+
+	labels := map[string]string{}
+	for _, pair := range strings.Split(val, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label pair %q in annotation %q", pair, key)
+		}
+		labels[kv[0]] = kv[1]
+	}
+
+	return labels, nil
+}
+
+func parseTaintsAnnotation(annotations map[string]string, key string) ([]corev1.Taint, error) {
+	val, found := annotations[key]
+	if !found || val == "" {
+		return nil, nil
+	}
+
+	var taints []corev1.Taint
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid taint entry %q in annotation %q", entry, key)
+		}
+
+		valueEffect := strings.SplitN(kv[1], ":", 2)
+		taint := corev1.Taint{Key: kv[0], Value: valueEffect[0]}
+		if len(valueEffect) == 2 {
+			taint.Effect = corev1.TaintEffect(valueEffect[1])
+		}
+
+		taints = append(taints, taint)
+	}
+
+	return taints, nil
+}
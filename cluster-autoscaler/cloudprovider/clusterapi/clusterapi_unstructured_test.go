@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterapi
+
+import "testing"
+
+func TestNormalizedProviderString(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "strips a scheme prefix",
+			in:   "aws:///us-east-1a/i-1234567890abcdef0",
+			want: "/us-east-1a/i-1234567890abcdef0",
+		},
+		{
+			name: "preserves the full path, not just the final segment",
+			in:   "azure:///subscriptions/sub/resourceGroups/rg-a/providers/Microsoft.Compute/virtualMachines/node-1",
+			want: "/subscriptions/sub/resourceGroups/rg-a/providers/Microsoft.Compute/virtualMachines/node-1",
+		},
+		{
+			name: "no scheme prefix is a no-op",
+			in:   "node-1",
+			want: "node-1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizedProviderString(tc.in); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestNormalizedProviderStringDistinguishesSameNameDifferentPath verifies
+// the fix for the bug normalizedProviderString used to have: truncating to
+// the final "/" segment would conflate two providerIDs differing only in
+// an earlier path component (e.g. two Azure VMs named identically across
+// resource groups), which findMachineByProviderID/findMachinePoolForProviderID
+// rely on to disambiguate Machines across namespaces.
+func TestNormalizedProviderStringDistinguishesSameNameDifferentPath(t *testing.T) {
+	a := normalizedProviderString("azure:///subscriptions/sub/resourceGroups/rg-a/providers/Microsoft.Compute/virtualMachines/node-1")
+	b := normalizedProviderString("azure:///subscriptions/sub/resourceGroups/rg-b/providers/Microsoft.Compute/virtualMachines/node-1")
+
+	if a == b {
+		t.Errorf("expected providerIDs differing only in resource group to normalize to different values, both got %q", a)
+	}
+}
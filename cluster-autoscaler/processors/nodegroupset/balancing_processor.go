@@ -0,0 +1,119 @@
+package nodegroupset
+
+import (
+	"fmt"
+
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// NodeInfoSimilarityComparator is NodeInfoComparator's detail-returning
+// counterpart, reporting exactly which check failed instead of a bare
+// bool. BalancingNodeGroupSetProcessor prefers this over Comparator when
+// both are set, since only the SimilarityResult can be fed to metrics,
+// logs, and the /debug/nodegroupsets handler.
+type NodeInfoSimilarityComparator func(n1, n2 *schedulernodeinfo.NodeInfo) SimilarityResult
+
+// adaptComparator bridges a legacy bool-returning NodeInfoComparator into
+// a NodeInfoSimilarityComparator, so BalancingNodeGroupSetProcessor can
+// still record diagnostics for callers - or tests - that only set the
+// old Comparator field. The adapted result necessarily omits which
+// resource/label caused a mismatch, since the bool comparator never
+// reported that.
+func adaptComparator(c NodeInfoComparator) NodeInfoSimilarityComparator {
+	return func(n1, n2 *schedulernodeinfo.NodeInfo) SimilarityResult {
+		if c(n1, n2) {
+			return similarResult
+		}
+		return SimilarityResult{Reason: MismatchReasonUnknown}
+	}
+}
+
+// BalancingNodeGroupSetProcessor is a NodeGroupSetProcessor that groups
+// node groups using a single comparator, so that scale-up can be balanced
+// across e.g. MachineSets spanning multiple availability zones.
+type BalancingNodeGroupSetProcessor struct {
+	// Comparator is the legacy bool-returning comparator, kept for
+	// backward compatibility with callers constructing a
+	// BalancingNodeGroupSetProcessor directly. Prefer ResultComparator.
+	Comparator NodeInfoComparator
+	// ResultComparator is Comparator's detail-returning counterpart. When
+	// set, it takes precedence over Comparator, and its SimilarityResult
+	// is fed to recordSimilarityResult for metrics/logging/debugging.
+	ResultComparator NodeInfoSimilarityComparator
+}
+
+// comparator resolves the comparator to use, preferring ResultComparator
+// and falling back to adapting the legacy Comparator field.
+func (b *BalancingNodeGroupSetProcessor) comparator() NodeInfoSimilarityComparator {
+	if b.ResultComparator != nil {
+		return b.ResultComparator
+	}
+	return adaptComparator(b.Comparator)
+}
+
+// FindSimilarNodeGroups returns every group in candidates whose
+// representative NodeInfo is similar to nodeGroup's, according to
+// b.comparator(). Every comparison - similar or not - is recorded via
+// recordSimilarityResult, so mismatches show up in metrics/logs/the
+// /debug/nodegroupsets handler even when the overall scale-up proceeds
+// with a smaller balanced set.
+func (b *BalancingNodeGroupSetProcessor) FindSimilarNodeGroups(nodeGroup cloudprovider.NodeGroup, candidates []cloudprovider.NodeGroup, nodeInfosForGroups map[string]*schedulernodeinfo.NodeInfo) ([]cloudprovider.NodeGroup, error) {
+	groupNodeInfo, found := nodeInfosForGroups[nodeGroup.Id()]
+	if !found {
+		return nil, fmt.Errorf("failed to find node info for group %v", nodeGroup.Id())
+	}
+
+	compare := b.comparator()
+	var result []cloudprovider.NodeGroup
+	for _, candidate := range candidates {
+		if candidate.Id() == nodeGroup.Id() {
+			continue
+		}
+
+		candidateNodeInfo, found := nodeInfosForGroups[candidate.Id()]
+		if !found {
+			continue
+		}
+
+		similarity := compare(groupNodeInfo, candidateNodeInfo)
+		recordSimilarityResult(nodeGroup.Id(), candidate.Id(), similarity)
+		if similarity.Similar {
+			result = append(result, candidate)
+		}
+	}
+
+	return result, nil
+}
+
+// CleanUp is a no-op; BalancingNodeGroupSetProcessor holds no resources
+// that need releasing.
+func (b *BalancingNodeGroupSetProcessor) CleanUp() {}
+
+// NewDefaultNodeGroupSetProcessor returns a BalancingNodeGroupSetProcessor
+// using IsOpenShiftMachineApiNodeInfoSimilar, i.e.
+// DefaultNodeInfoComparatorConfig's tolerances and ignored labels.
+func NewDefaultNodeGroupSetProcessor() NodeGroupSetProcessor {
+	return &BalancingNodeGroupSetProcessor{
+		Comparator: IsOpenShiftMachineApiNodeInfoSimilar,
+		ResultComparator: func(n1, n2 *schedulernodeinfo.NodeInfo) SimilarityResult {
+			return isOpenShiftNodeInfoSimilarResult(n1, n2, DefaultNodeInfoComparatorConfig())
+		},
+	}
+}
+
+// NewNodeGroupSetProcessor returns a BalancingNodeGroupSetProcessor that
+// compares node groups using the given config, so a cloud provider can
+// register tolerances/ignored-labels suited to its own instance catalog
+// instead of relying on DefaultNodeInfoComparatorConfig.
+func NewNodeGroupSetProcessor(config NodeInfoComparatorConfig) NodeGroupSetProcessor {
+	return &BalancingNodeGroupSetProcessor{
+		Comparator: func(n1, n2 *schedulernodeinfo.NodeInfo) bool {
+			return IsOpenShiftMachineApiNodeInfoSimilarWithConfig(n1, n2, config)
+		},
+		ResultComparator: func(n1, n2 *schedulernodeinfo.NodeInfo) SimilarityResult {
+			return isOpenShiftNodeInfoSimilarResult(n1, n2, config)
+		},
+	}
+}
@@ -0,0 +1,252 @@
+package nodegroupset
+
+import (
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// MaxAllocatableDifferenceRatio describes how much Node.Status.Allocatable
+	// can differ between two nodes of node groups considered part of the same
+	// NodeGroupSet, expressed as a ratio of the larger quantity.
+	MaxAllocatableDifferenceRatio = 0.05
+	// MaxFreeDifferenceRatio describes how much the free (allocatable minus
+	// requested) capacity can differ between two such nodes.
+	MaxFreeDifferenceRatio = 0.05
+)
+
+// NodeInfoComparatorConfig holds the tolerances and ignored-label set an
+// isOpenShiftNodeInfoSimilar-family comparator uses to decide whether two
+// nodes are alike enough to belong to the same NodeGroupSet. Cloud
+// providers that know their own instance catalog's quirks - e.g. a few
+// tens of MiB of reserved memory drift between otherwise-identical
+// flavors, or topology labels their CSI driver injects - register their
+// own config via NewNodeGroupSetProcessor instead of relying on
+// DefaultNodeInfoComparatorConfig.
+type NodeInfoComparatorConfig struct {
+	// MaxCapacityMemoryDifference bounds the absolute difference tolerated
+	// for memory and ephemeral-storage, e.g. 128Ki, in capacity,
+	// allocatable and free alike.
+	MaxCapacityMemoryDifference resource.Quantity
+	// MaxAllocatableDifferenceRatio bounds the Node.Status.Capacity and
+	// Node.Status.Allocatable difference tolerated for
+	// cpu/pods/hugepages/extended resources (memory and ephemeral-storage
+	// instead use MaxCapacityMemoryDifference as an absolute threshold).
+	MaxAllocatableDifferenceRatio float64
+	// MaxFreeDifferenceRatio bounds the (allocatable - requested)
+	// difference tolerated, using the same per-resource split as
+	// MaxAllocatableDifferenceRatio.
+	MaxFreeDifferenceRatio float64
+	// IgnoredLabels lists node labels excluded from the label-equality
+	// check, e.g. zone/region labels or cloud-injected CSI topology labels
+	// (such as topology.ebs.csi.aws.com/zone) that legitimately differ
+	// between otherwise-interchangeable nodes.
+	IgnoredLabels map[string]bool
+	// AuthoritativeLabelPrefixes lists label-key prefixes whose presence
+	// on only one of the two compared nodes must not, by itself, make the
+	// node groups look dissimilar - e.g. node-role.kubernetes.io/* labels
+	// a scale-from-zero template built from annotations hasn't picked up
+	// yet, but the kubelet will add once a real Node joins. A value
+	// mismatch between two labels sharing such a prefix is still treated
+	// as a difference; only one-sided presence is forgiven.
+	AuthoritativeLabelPrefixes []string
+}
+
+// DefaultNodeInfoComparatorConfig returns the comparator config used when a
+// cloud provider has not registered anything more specific.
+func DefaultNodeInfoComparatorConfig() NodeInfoComparatorConfig {
+	return NodeInfoComparatorConfig{
+		MaxCapacityMemoryDifference:   maxMemoryDifferenceInKiloBytes,
+		MaxAllocatableDifferenceRatio: MaxAllocatableDifferenceRatio,
+		MaxFreeDifferenceRatio:        MaxFreeDifferenceRatio,
+		IgnoredLabels: map[string]bool{
+			apiv1.LabelHostname:                  true,
+			apiv1.LabelZoneFailureDomain:         true,
+			apiv1.LabelZoneRegion:                true,
+			"beta.kubernetes.io/fluentd-ds-ready": true, // this is internal label used for determining if fluentd should be installed as deamon set. Used for migration 1.8 to 1.9.
+		},
+	}
+}
+
+// SimilarityMismatchReason enumerates the family of check that failed a
+// SimilarityResult, so callers - metrics, logs, the /debug/nodegroupsets
+// handler - can group mismatches without parsing free text.
+type SimilarityMismatchReason string
+
+const (
+	// MismatchReasonCapacity means Node.Status.Capacity differed by more
+	// than the configured tolerance for some resource.
+	MismatchReasonCapacity SimilarityMismatchReason = "capacity"
+	// MismatchReasonAllocatable means Node.Status.Allocatable differed by
+	// more than the configured tolerance for some resource.
+	MismatchReasonAllocatable SimilarityMismatchReason = "allocatable"
+	// MismatchReasonFree means (allocatable - requested) differed by more
+	// than the configured tolerance for some resource.
+	MismatchReasonFree SimilarityMismatchReason = "free"
+	// MismatchReasonLabel means a label was missing from one node or had
+	// different values on the two nodes.
+	MismatchReasonLabel SimilarityMismatchReason = "label"
+	// MismatchReasonModelBucket means the two nodes fell into different
+	// resource-model tiers, per IsNodeInfoSimilarByResourceModelResult.
+	MismatchReasonModelBucket SimilarityMismatchReason = "model_bucket"
+	// MismatchReasonUnknown is used by adaptComparator, for a legacy
+	// bool-only NodeInfoComparator that can report "not similar" but not
+	// which check failed.
+	MismatchReasonUnknown SimilarityMismatchReason = "unknown"
+)
+
+// SimilarityResult reports whether two nodes were considered similar and,
+// if not, exactly which check failed: the resource name and both
+// quantities for a capacity/allocatable/free/model-bucket mismatch, or the
+// offending label key and both values for a label mismatch. This is the
+// detail a human - or a Prometheus label - needs to tell "nodes not
+// balanced because of a 2Gi memory drift" apart from "nodes not balanced
+// because of a stray zone label", rather than a bare bool.
+type SimilarityResult struct {
+	// Similar is true if no mismatch was found.
+	Similar bool
+	// Reason is the family of check that failed. Zero value when Similar.
+	Reason SimilarityMismatchReason
+	// Resource is the resource name involved in a
+	// capacity/allocatable/free/model-bucket mismatch. Empty for a label
+	// mismatch or when Similar.
+	Resource apiv1.ResourceName
+	// Label is the label key involved in a label mismatch. Empty
+	// otherwise.
+	Label string
+	// ValueA and ValueB are the two compared values, formatted for
+	// logging/metrics (a quantity's string form, or a label value).
+	ValueA string
+	ValueB string
+}
+
+// similarResult is the zero-value "no mismatch found" result.
+var similarResult = SimilarityResult{Similar: true}
+
+// compareResourceMapsWithTolerance returns true if, for every resource in
+// resourceMap, its (exactly two) recorded quantities are within
+// maxDifferenceRatio of one another - except for memory and
+// ephemeral-storage, which instead use
+// config.MaxCapacityMemoryDifference as an absolute threshold, since a
+// fixed amount of reserved memory matters far more on small instances
+// than a percentage would suggest.
+func compareResourceMapsWithTolerance(resourceMap map[apiv1.ResourceName][]resource.Quantity, maxDifferenceRatio float64, config NodeInfoComparatorConfig) bool {
+	return firstMapMismatch(resourceMap, maxDifferenceRatio, config, MismatchReasonAllocatable).Similar
+}
+
+// sortedResourceNames returns the keys of resourceMap in a fixed,
+// deterministic order, so that callers reporting "the first mismatching
+// resource" give the same answer on every call for the same inputs rather
+// than one that depends on Go's randomized map iteration order.
+func sortedResourceNames(resourceMap map[apiv1.ResourceName][]resource.Quantity) []apiv1.ResourceName {
+	names := make([]apiv1.ResourceName, 0, len(resourceMap))
+	for res := range resourceMap {
+		names = append(names, res)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// sortedLabelKeys returns the keys of labels in a fixed, deterministic
+// order, for the same reason as sortedResourceNames.
+func sortedLabelKeys(labels map[string][]string) []string {
+	keys := make([]string, 0, len(labels))
+	for label := range labels {
+		keys = append(keys, label)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// firstMapMismatch is compareResourceMapsWithTolerance's detail-returning
+// counterpart: it reports the first resource, in sorted order, that fails
+// the tolerance check, tagged with reason (MismatchReasonCapacity,
+// MismatchReasonAllocatable or MismatchReasonFree, depending on which map
+// the caller passed).
+func firstMapMismatch(resourceMap map[apiv1.ResourceName][]resource.Quantity, maxDifferenceRatio float64, config NodeInfoComparatorConfig, reason SimilarityMismatchReason) SimilarityResult {
+	for _, res := range sortedResourceNames(resourceMap) {
+		qtyList := resourceMap[res]
+		if len(qtyList) != 2 {
+			return SimilarityResult{Reason: reason, Resource: res}
+		}
+
+		if res == apiv1.ResourceMemory || res == apiv1.ResourceEphemeralStorage {
+			if !compareResourceEqualWithTolerance(qtyList[0], qtyList[1], config.MaxCapacityMemoryDifference) {
+				return SimilarityResult{Reason: reason, Resource: res, ValueA: qtyList[0].String(), ValueB: qtyList[1].String()}
+			}
+			continue
+		}
+
+		if !compareResourceApproximatelyEqual(qtyList[0], qtyList[1], maxDifferenceRatio) {
+			return SimilarityResult{Reason: reason, Resource: res, ValueA: qtyList[0].String(), ValueB: qtyList[1].String()}
+		}
+	}
+	return similarResult
+}
+
+// firstCapacityMismatch is firstMapMismatch applied to the capacity map:
+// memory and ephemeral-storage use config.MaxCapacityMemoryDifference as an
+// absolute threshold, every other resource uses
+// config.MaxAllocatableDifferenceRatio.
+func firstCapacityMismatch(capacity map[apiv1.ResourceName][]resource.Quantity, config NodeInfoComparatorConfig) SimilarityResult {
+	return firstMapMismatch(capacity, config.MaxAllocatableDifferenceRatio, config, MismatchReasonCapacity)
+}
+
+// firstLabelMismatch reports the first label, in sorted order, missing
+// from one node (and not tolerated by an AuthoritativeLabelPrefixes
+// prefix) or present with different values on the two.
+func firstLabelMismatch(labels map[string][]string, config NodeInfoComparatorConfig) SimilarityResult {
+	for _, label := range sortedLabelKeys(labels) {
+		labelValues := labels[label]
+		if len(labelValues) == 2 {
+			if labelValues[0] != labelValues[1] {
+				return SimilarityResult{Reason: MismatchReasonLabel, Label: label, ValueA: labelValues[0], ValueB: labelValues[1]}
+			}
+			continue
+		}
+		// Present on only one of the two nodes - e.g. a scale-from-zero
+		// template built from labelsKey hasn't picked up a label the
+		// kubelet later adds to a real Node, or vice versa. That alone
+		// must not reject the group for labels under an
+		// AuthoritativeLabelPrefixes prefix.
+		if !hasAuthoritativePrefix(label, config.AuthoritativeLabelPrefixes) {
+			return SimilarityResult{Reason: MismatchReasonLabel, Label: label, ValueA: labelValues[0]}
+		}
+	}
+	return similarResult
+}
+
+// hasAuthoritativePrefix reports whether label starts with any of
+// prefixes.
+func hasAuthoritativePrefix(label string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(label, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareResourceApproximatelyEqual returns true if x and y are within
+// maxDifferenceRatio of the larger of the two.
+func compareResourceApproximatelyEqual(x, y resource.Quantity, maxDifferenceRatio float64) bool {
+	larger := x
+	if y.Cmp(x) > 0 {
+		larger = y
+	}
+
+	diff := x.DeepCopy()
+	diff.Sub(y)
+	if diff.Sign() == -1 {
+		diff.Neg()
+	}
+
+	if larger.MilliValue() == 0 {
+		return diff.MilliValue() == 0
+	}
+
+	return float64(diff.MilliValue())/float64(larger.MilliValue()) <= maxDifferenceRatio
+}
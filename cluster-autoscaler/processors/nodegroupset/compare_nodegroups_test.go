@@ -0,0 +1,163 @@
+package nodegroupset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+)
+
+func buildBasicNodeInfo(name, arch string, cpu, memoryMiB int64) *schedulernodeinfo.NodeInfo {
+	capacity := apiv1.ResourceList{
+		apiv1.ResourceCPU:    *resource.NewQuantity(cpu, resource.DecimalSI),
+		apiv1.ResourceMemory: *resource.NewQuantity(memoryMiB*1024*1024, resource.BinarySI),
+		apiv1.ResourcePods:   *resource.NewQuantity(110, resource.DecimalSI),
+	}
+
+	node := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				apiv1.LabelArchStable: arch,
+				apiv1.LabelOSStable:   "linux",
+			},
+		},
+		Status: apiv1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: capacity,
+		},
+	}
+
+	nodeInfo := schedulernodeinfo.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	return nodeInfo
+}
+
+func TestIsOpenShiftMachineApiNodeInfoSimilarMixedArchCapacityDrift(t *testing.T) {
+	// arm64 and amd64 flavors of "the same" node group frequently report
+	// kubelet capacity a few tens of MiB apart, comfortably outside the
+	// default 128Ki tolerance.
+	amd64Node := buildBasicNodeInfo("amd64-node", "amd64", 4, 16384)
+	arm64Node := buildBasicNodeInfo("arm64-node", "arm64", 4, 16352)
+
+	assert.False(t, IsOpenShiftMachineApiNodeInfoSimilar(amd64Node, arm64Node),
+		"expected default tolerance to reject a tens-of-MiB capacity drift")
+
+	config := DefaultNodeInfoComparatorConfig()
+	config.MaxCapacityMemoryDifference = resource.MustParse("64Mi")
+	config.IgnoredLabels[apiv1.LabelArchStable] = true
+
+	assert.True(t, IsOpenShiftMachineApiNodeInfoSimilarWithConfig(amd64Node, arm64Node, config),
+		"expected a 64Mi tolerance and an ignored arch label to consider the nodes similar")
+}
+
+func TestIsOpenShiftMachineApiNodeInfoSimilarAuthoritativeLabelPrefixes(t *testing.T) {
+	n1 := buildBasicNodeInfo("n1", "amd64", 4, 16384)
+	n2 := buildBasicNodeInfo("n2", "amd64", 4, 16384)
+	n2.Node().Labels["node-role.kubernetes.io/worker"] = "true"
+
+	config := DefaultNodeInfoComparatorConfig()
+	assert.False(t, IsOpenShiftMachineApiNodeInfoSimilarWithConfig(n1, n2, config),
+		"a label present on only one node should reject the group without an authoritative prefix configured")
+
+	config.AuthoritativeLabelPrefixes = []string{"node-role.kubernetes.io/"}
+	assert.True(t, IsOpenShiftMachineApiNodeInfoSimilarWithConfig(n1, n2, config),
+		"a one-sided label under an authoritative prefix should not reject the group")
+
+	n1.Node().Labels["node-role.kubernetes.io/worker"] = "false"
+	assert.False(t, IsOpenShiftMachineApiNodeInfoSimilarWithConfig(n1, n2, config),
+		"a value mismatch under an authoritative prefix is still a real difference")
+}
+
+func TestCompareResourceMapsWithToleranceUsesAbsoluteThresholdForMemory(t *testing.T) {
+	config := DefaultNodeInfoComparatorConfig()
+	config.MaxCapacityMemoryDifference = resource.MustParse("256Ki")
+
+	resourceMap := map[apiv1.ResourceName][]resource.Quantity{
+		apiv1.ResourceMemory: {
+			*resource.NewQuantity(1024*1024*1024, resource.BinarySI),
+			*resource.NewQuantity(1024*1024*1024+512*1024, resource.BinarySI),
+		},
+	}
+
+	assert.False(t, compareResourceMapsWithTolerance(resourceMap, MaxAllocatableDifferenceRatio, config),
+		"a 512Ki difference should exceed a 256Ki absolute threshold regardless of the ratio tolerance")
+
+	config.MaxCapacityMemoryDifference = resource.MustParse("1Mi")
+	assert.True(t, compareResourceMapsWithTolerance(resourceMap, MaxAllocatableDifferenceRatio, config),
+		"a 512Ki difference is within a 1Mi absolute threshold")
+}
+
+func TestIsOpenShiftNodeInfoSimilarResultReportsCapacityMismatch(t *testing.T) {
+	n1 := buildBasicNodeInfo("n1", "amd64", 4, 16384)
+	n2 := buildBasicNodeInfo("n2", "amd64", 4, 8192)
+
+	result := isOpenShiftNodeInfoSimilarResult(n1, n2, DefaultNodeInfoComparatorConfig())
+	assert.False(t, result.Similar)
+	assert.Equal(t, MismatchReasonCapacity, result.Reason)
+	assert.Equal(t, apiv1.ResourceMemory, result.Resource)
+}
+
+func TestIsOpenShiftNodeInfoSimilarResultReportsLabelMismatch(t *testing.T) {
+	n1 := buildBasicNodeInfo("n1", "amd64", 4, 16384)
+	n2 := buildBasicNodeInfo("n2", "amd64", 4, 16384)
+	n2.Node().Labels["topology.example.com/rack"] = "rack-1"
+
+	result := isOpenShiftNodeInfoSimilarResult(n1, n2, DefaultNodeInfoComparatorConfig())
+	assert.False(t, result.Similar)
+	assert.Equal(t, MismatchReasonLabel, result.Reason)
+	assert.Equal(t, "topology.example.com/rack", result.Label)
+}
+
+// TestIsOpenShiftNodeInfoSimilarResultIsDeterministicAcrossMismatches
+// verifies that when two nodes differ in more than one capacity resource,
+// isOpenShiftNodeInfoSimilarResult always reports the same resource - cpu
+// sorts before memory - rather than whichever one Go's randomized map
+// iteration happened to visit first.
+func TestIsOpenShiftNodeInfoSimilarResultIsDeterministicAcrossMismatches(t *testing.T) {
+	n1 := buildBasicNodeInfo("n1", "amd64", 4, 16384)
+	n2 := buildBasicNodeInfo("n2", "amd64", 8, 8192)
+
+	for i := 0; i < 10; i++ {
+		result := isOpenShiftNodeInfoSimilarResult(n1, n2, DefaultNodeInfoComparatorConfig())
+		assert.False(t, result.Similar)
+		assert.Equal(t, MismatchReasonCapacity, result.Reason)
+		assert.Equal(t, apiv1.ResourceCPU, result.Resource)
+	}
+}
+
+// TestIsOpenShiftNodeInfoSimilarResultLabelMismatchIsDeterministic is the
+// label-mismatch analogue of
+// TestIsOpenShiftNodeInfoSimilarResultIsDeterministicAcrossMismatches: with
+// two mismatching labels present, the one reported must always be the
+// alphabetically first.
+func TestIsOpenShiftNodeInfoSimilarResultLabelMismatchIsDeterministic(t *testing.T) {
+	n1 := buildBasicNodeInfo("n1", "amd64", 4, 16384)
+	n2 := buildBasicNodeInfo("n2", "amd64", 4, 16384)
+	n2.Node().Labels["zzz-label"] = "only-on-n2"
+	n2.Node().Labels["aaa-label"] = "only-on-n2"
+
+	for i := 0; i < 10; i++ {
+		result := isOpenShiftNodeInfoSimilarResult(n1, n2, DefaultNodeInfoComparatorConfig())
+		assert.False(t, result.Similar)
+		assert.Equal(t, MismatchReasonLabel, result.Reason)
+		assert.Equal(t, "aaa-label", result.Label)
+	}
+}
+
+func TestCompareResourceMapsWithToleranceUsesRatioForOtherResources(t *testing.T) {
+	config := DefaultNodeInfoComparatorConfig()
+
+	resourceMap := map[apiv1.ResourceName][]resource.Quantity{
+		apiv1.ResourceCPU: {
+			*resource.NewQuantity(100, resource.DecimalSI),
+			*resource.NewQuantity(104, resource.DecimalSI),
+		},
+	}
+
+	assert.True(t, compareResourceMapsWithTolerance(resourceMap, 0.05, config))
+	assert.False(t, compareResourceMapsWithTolerance(resourceMap, 0.01, config))
+}
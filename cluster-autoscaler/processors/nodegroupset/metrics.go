@@ -0,0 +1,114 @@
+package nodegroupset
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+// debugMismatchBufferSize bounds how many recent mismatches
+// DebugHandler can dump, so a cluster that never balances doesn't grow
+// this buffer without limit.
+const debugMismatchBufferSize = 200
+
+// similarityMismatchCount counts every SimilarityResult recording a
+// mismatch, broken down by reason and, where applicable, the offending
+// resource or label - the data an operator needs to tell a one-off arch
+// drift apart from a persistently misconfigured tolerance.
+var similarityMismatchCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "cluster_autoscaler",
+		Name:      "nodegroup_similarity_mismatch_total",
+		Help:      "Number of times two node groups expected to belong to the same NodeGroupSet were found dissimilar, by reason/resource/label.",
+	}, []string{"reason", "resource", "label"},
+)
+
+func init() {
+	prometheus.MustRegister(similarityMismatchCount)
+}
+
+// MismatchRecord is one entry in the mismatch ring buffer: a
+// SimilarityResult plus the two NodeGroup IDs that were being compared,
+// since the comparator itself only ever sees NodeInfos.
+type MismatchRecord struct {
+	NodeGroupID          string `json:"nodeGroupId"`
+	CandidateNodeGroupID string `json:"candidateNodeGroupId"`
+	SimilarityResult
+}
+
+// mismatchBuffer is the process-wide ring buffer DebugHandler serves.
+var mismatchBuffer = newMismatchRingBuffer(debugMismatchBufferSize)
+
+// mismatchRingBuffer is a fixed-capacity, concurrency-safe ring buffer of
+// the most recently recorded mismatches.
+type mismatchRingBuffer struct {
+	mu       sync.Mutex
+	records  []MismatchRecord
+	capacity int
+	next     int
+	full     bool
+}
+
+func newMismatchRingBuffer(capacity int) *mismatchRingBuffer {
+	return &mismatchRingBuffer{records: make([]MismatchRecord, capacity), capacity: capacity}
+}
+
+func (b *mismatchRingBuffer) add(record MismatchRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records[b.next] = record
+	b.next = (b.next + 1) % b.capacity
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// recent returns the buffer's contents, oldest first.
+func (b *mismatchRingBuffer) recent() []MismatchRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		out := make([]MismatchRecord, b.next)
+		copy(out, b.records[:b.next])
+		return out
+	}
+	out := make([]MismatchRecord, b.capacity)
+	copy(out, b.records[b.next:])
+	copy(out[b.capacity-b.next:], b.records[:b.next])
+	return out
+}
+
+// recordSimilarityResult updates the similarity-mismatch counter, the
+// debug ring buffer, and emits a debug-level log line, for result's
+// describing a mismatch between nodeGroupID and candidateNodeGroupID. It
+// is a no-op when result.Similar, so call sites can call it
+// unconditionally after every comparison.
+func recordSimilarityResult(nodeGroupID, candidateNodeGroupID string, result SimilarityResult) {
+	if result.Similar {
+		return
+	}
+
+	similarityMismatchCount.WithLabelValues(string(result.Reason), string(result.Resource), result.Label).Inc()
+	mismatchBuffer.add(MismatchRecord{
+		NodeGroupID:          nodeGroupID,
+		CandidateNodeGroupID: candidateNodeGroupID,
+		SimilarityResult:     result,
+	})
+	klog.V(4).Infof("node groups %s and %s not balanced: reason=%s resource=%s label=%s valueA=%s valueB=%s",
+		nodeGroupID, candidateNodeGroupID, result.Reason, result.Resource, result.Label, result.ValueA, result.ValueB)
+}
+
+// DebugHandler serves the most recent mismatches recorded by
+// recordSimilarityResult as JSON, oldest first. Mount it at
+// /debug/nodegroupsets to give operators the evidence needed to tune the
+// tolerance/ignored-label config instead of only seeing "nodes not
+// balanced" with no further explanation.
+func DebugHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mismatchBuffer.recent()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
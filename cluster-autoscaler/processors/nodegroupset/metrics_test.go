@@ -0,0 +1,43 @@
+package nodegroupset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordSimilarityResultIgnoresSimilar(t *testing.T) {
+	mismatchBuffer = newMismatchRingBuffer(debugMismatchBufferSize)
+
+	recordSimilarityResult("a", "b", similarResult)
+
+	assert.Empty(t, mismatchBuffer.recent())
+}
+
+func TestRecordSimilarityResultBuffersMismatch(t *testing.T) {
+	mismatchBuffer = newMismatchRingBuffer(debugMismatchBufferSize)
+
+	result := SimilarityResult{Reason: MismatchReasonLabel, Label: "topology.example.com/rack", ValueA: "rack-1"}
+	recordSimilarityResult("group", "candidate", result)
+
+	recent := mismatchBuffer.recent()
+	if assert.Len(t, recent, 1) {
+		assert.Equal(t, "group", recent[0].NodeGroupID)
+		assert.Equal(t, "candidate", recent[0].CandidateNodeGroupID)
+		assert.Equal(t, result, recent[0].SimilarityResult)
+	}
+}
+
+func TestMismatchRingBufferWrapsAtCapacity(t *testing.T) {
+	buf := newMismatchRingBuffer(2)
+
+	buf.add(MismatchRecord{NodeGroupID: "1"})
+	buf.add(MismatchRecord{NodeGroupID: "2"})
+	buf.add(MismatchRecord{NodeGroupID: "3"})
+
+	recent := buf.recent()
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, "2", recent[0].NodeGroupID)
+		assert.Equal(t, "3", recent[1].NodeGroupID)
+	}
+}
@@ -0,0 +1,25 @@
+package nodegroupset
+
+import (
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// NodeInfoComparator decides whether two nodes are similar enough that
+// their node groups should be treated as part of the same NodeGroupSet
+// for the purposes of balanced scale-up.
+type NodeInfoComparator func(n1, n2 *schedulernodeinfo.NodeInfo) bool
+
+// NodeGroupSetProcessor finds the node groups that should be treated as a
+// single logical pool when scaling up, given a representative NodeInfo for
+// each candidate group.
+type NodeGroupSetProcessor interface {
+	// FindSimilarNodeGroups returns every group in candidates whose
+	// representative NodeInfo is similar to nodeGroup's, per the
+	// processor's configured comparator.
+	FindSimilarNodeGroups(nodeGroup cloudprovider.NodeGroup, candidates []cloudprovider.NodeGroup, nodeInfosForGroups map[string]*schedulernodeinfo.NodeInfo) ([]cloudprovider.NodeGroup, error)
+
+	// CleanUp releases any resources held by the processor.
+	CleanUp()
+}
@@ -11,14 +11,32 @@ import (
 var maxMemoryDifferenceInKiloBytes = resource.MustParse("128Ki")
 
 // IsOpenShiftMachineApiNodeInfoSimilar compares if two nodes should
-// be considered part of the same NodeGroupSet.
+// be considered part of the same NodeGroupSet, using
+// DefaultNodeInfoComparatorConfig.
 func IsOpenShiftMachineApiNodeInfoSimilar(n1, n2 *schedulernodeinfo.NodeInfo) bool {
-	return isOpenShiftNodeInfoSimilar(n1, n2)
+	return isOpenShiftNodeInfoSimilar(n1, n2, DefaultNodeInfoComparatorConfig())
+}
+
+// IsOpenShiftMachineApiNodeInfoSimilarWithConfig behaves like
+// IsOpenShiftMachineApiNodeInfoSimilar but lets the caller - typically a
+// NodeGroupSetProcessor a cloud provider registered with its own
+// NodeInfoComparatorConfig - override the tolerance/ignored-label
+// configuration.
+func IsOpenShiftMachineApiNodeInfoSimilarWithConfig(n1, n2 *schedulernodeinfo.NodeInfo, config NodeInfoComparatorConfig) bool {
+	return isOpenShiftNodeInfoSimilar(n1, n2, config)
 }
 
 // Note: this is a copy of isNodeInfoSimilar() and the only change is
 // to tolerate a small memory capacity difference.
-func isOpenShiftNodeInfoSimilar(n1, n2 *schedulernodeinfo.NodeInfo) bool {
+func isOpenShiftNodeInfoSimilar(n1, n2 *schedulernodeinfo.NodeInfo, config NodeInfoComparatorConfig) bool {
+	return isOpenShiftNodeInfoSimilarResult(n1, n2, config).Similar
+}
+
+// isOpenShiftNodeInfoSimilarResult is isOpenShiftNodeInfoSimilar's
+// detail-returning counterpart, reporting exactly which check - and which
+// resource or label - failed first, so a caller can feed it to metrics,
+// logs, or the /debug/nodegroupsets handler instead of a bare bool.
+func isOpenShiftNodeInfoSimilarResult(n1, n2 *schedulernodeinfo.NodeInfo, config NodeInfoComparatorConfig) SimilarityResult {
 	capacity := make(map[apiv1.ResourceName][]resource.Quantity)
 	allocatable := make(map[apiv1.ResourceName][]resource.Quantity)
 	free := make(map[apiv1.ResourceName][]resource.Quantity)
@@ -44,41 +62,27 @@ func isOpenShiftNodeInfoSimilar(n1, n2 *schedulernodeinfo.NodeInfo) bool {
 	// See:
 	// https://bugzilla.redhat.com/show_bug.cgi?id=1731011
 	// https://bugzilla.redhat.com/show_bug.cgi?id=1733235
-	for _, qtyList := range capacity {
-		if len(qtyList) != 2 || !compareResourceEqualWithTolerance(qtyList[0], qtyList[1], maxMemoryDifferenceInKiloBytes) {
-			return false
-		}
+	if result := firstCapacityMismatch(capacity, config); !result.Similar {
+		return result
 	}
 	// For allocatable and free we allow resource quantities to be within a few % of each other
-	if !compareResourceMapsWithTolerance(allocatable, MaxAllocatableDifferenceRatio) {
-		return false
+	if result := firstMapMismatch(allocatable, config.MaxAllocatableDifferenceRatio, config, MismatchReasonAllocatable); !result.Similar {
+		return result
 	}
-	if !compareResourceMapsWithTolerance(free, MaxFreeDifferenceRatio) {
-		return false
-	}
-
-	ignoredLabels := map[string]bool{
-		apiv1.LabelHostname:                   true,
-		apiv1.LabelZoneFailureDomain:          true,
-		apiv1.LabelZoneRegion:                 true,
-		"beta.kubernetes.io/fluentd-ds-ready": true, // this is internal label used for determining if fluentd should be installed as deamon set. Used for migration 1.8 to 1.9.
+	if result := firstMapMismatch(free, config.MaxFreeDifferenceRatio, config, MismatchReasonFree); !result.Similar {
+		return result
 	}
 
 	labels := make(map[string][]string)
 	for _, node := range nodes {
 		for label, value := range node.Node().ObjectMeta.Labels {
-			ignore, _ := ignoredLabels[label]
-			if !ignore {
-				labels[label] = append(labels[label], value)
+			if config.IgnoredLabels[label] {
+				continue
 			}
+			labels[label] = append(labels[label], value)
 		}
 	}
-	for _, labelValues := range labels {
-		if len(labelValues) != 2 || labelValues[0] != labelValues[1] {
-			return false
-		}
-	}
-	return true
+	return firstLabelMismatch(labels, config)
 }
 
 func compareResourceEqualWithTolerance(x, y, tolerance resource.Quantity) bool {
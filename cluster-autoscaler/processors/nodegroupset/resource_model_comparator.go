@@ -0,0 +1,180 @@
+package nodegroupset
+
+import (
+	"fmt"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	gpuapis "k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
+)
+
+// sortedResourceModelNames returns the keys of bounds in a fixed,
+// deterministic order, so the first mismatching resource reported by
+// IsNodeInfoSimilarByResourceModelResult doesn't depend on Go's
+// randomized map iteration order.
+func sortedResourceModelNames(bounds map[apiv1.ResourceName][]resource.Quantity) []apiv1.ResourceName {
+	names := make([]apiv1.ResourceName, 0, len(bounds))
+	for res := range bounds {
+		names = append(names, res)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// defaultResourceModelTiers bounds how many power-of-two tiers
+// NewDefaultResourceModelConfig generates before everything larger falls
+// into the final, unbounded model.
+const defaultResourceModelTiers = 10
+
+// ResourceModelConfig maps each resource considered by
+// IsNodeInfoSimilarByResourceModel to the ascending, inclusive upper
+// bounds of its models: model i covers the range
+// (Bounds[res][i-1], Bounds[res][i]], with model 0 starting at 0 and the
+// model past the last bound covering everything above it. Operators load
+// a ResourceModelConfig from a config file at startup to override the
+// power-of-two default for any resource, e.g. to match their cloud's
+// actual instance size tiers.
+type ResourceModelConfig struct {
+	Bounds map[apiv1.ResourceName][]resource.Quantity
+}
+
+// NewDefaultResourceModelConfig returns the power-of-two tiered model
+// described in the design doc: model i covers CPU in (2^(i-1), 2^i] cores
+// and memory in (2^(i-1), 2^i] GiB, for i from 0 (covering (0,1]) up to
+// defaultResourceModelTiers-1, after which everything belongs to one final,
+// unbounded model.
+func NewDefaultResourceModelConfig() ResourceModelConfig {
+	cpuBounds := make([]resource.Quantity, 0, defaultResourceModelTiers)
+	memoryBounds := make([]resource.Quantity, 0, defaultResourceModelTiers)
+	gpuBounds := make([]resource.Quantity, 0, defaultResourceModelTiers)
+
+	for i := 0; i < defaultResourceModelTiers; i++ {
+		tierSize := int64(1) << uint(i)
+		cpuBounds = append(cpuBounds, *resource.NewQuantity(tierSize, resource.DecimalSI))
+		memoryBounds = append(memoryBounds, *resource.NewQuantity(tierSize*1024*1024*1024, resource.BinarySI))
+		gpuBounds = append(gpuBounds, *resource.NewQuantity(tierSize, resource.DecimalSI))
+	}
+
+	return ResourceModelConfig{
+		Bounds: map[apiv1.ResourceName][]resource.Quantity{
+			apiv1.ResourceCPU:         cpuBounds,
+			apiv1.ResourceMemory:      memoryBounds,
+			gpuapis.ResourceNvidiaGPU: gpuBounds,
+		},
+	}
+}
+
+// IsNodeInfoSimilarByResourceModel considers n1 and n2 similar when, for
+// every resource in config.Bounds, their Node.Status.Capacity falls into
+// the same model bucket - rather than being numerically close, as
+// isOpenShiftNodeInfoSimilar requires. This lets balancing treat e.g.
+// m5.2xlarge and m5a.2xlarge as one pool despite small capacity drift
+// between them, so long as they land in the same (cpu, memory, gpu, ...)
+// tier. Labels are still compared for exact equality, modulo
+// ignoredLabels.
+func IsNodeInfoSimilarByResourceModel(n1, n2 *schedulernodeinfo.NodeInfo, config ResourceModelConfig, ignoredLabels map[string]bool) bool {
+	return IsNodeInfoSimilarByResourceModelResult(n1, n2, config, ignoredLabels).Similar
+}
+
+// IsNodeInfoSimilarByResourceModelResult is
+// IsNodeInfoSimilarByResourceModel's detail-returning counterpart.
+func IsNodeInfoSimilarByResourceModelResult(n1, n2 *schedulernodeinfo.NodeInfo, config ResourceModelConfig, ignoredLabels map[string]bool) SimilarityResult {
+	for _, res := range sortedResourceModelNames(config.Bounds) {
+		bounds := config.Bounds[res]
+		b1 := resourceModelBucket(n1.Node().Status.Capacity[res], bounds)
+		b2 := resourceModelBucket(n2.Node().Status.Capacity[res], bounds)
+		if b1 != b2 {
+			return SimilarityResult{
+				Reason:   MismatchReasonModelBucket,
+				Resource: res,
+				ValueA:   n1.Node().Status.Capacity[res].String(),
+				ValueB:   n2.Node().Status.Capacity[res].String(),
+			}
+		}
+	}
+
+	return firstLabelMismatchAcross([]*schedulernodeinfo.NodeInfo{n1, n2}, ignoredLabels)
+}
+
+// resourceModelBucket returns the index of the first bound that is
+// greater than or equal to qty, found by binary search since bounds is
+// sorted ascending, or len(bounds) if qty exceeds every bound. A value
+// exactly equal to bounds[i] therefore belongs to bucket i, not i+1.
+func resourceModelBucket(qty resource.Quantity, bounds []resource.Quantity) int {
+	return sort.Search(len(bounds), func(i int) bool {
+		return bounds[i].Cmp(qty) >= 0
+	})
+}
+
+// compareNodeLabelsEqual returns true if every non-ignored label present
+// on any of nodes has the same value across all of them.
+func compareNodeLabelsEqual(nodes []*schedulernodeinfo.NodeInfo, ignoredLabels map[string]bool) bool {
+	return firstLabelMismatchAcross(nodes, ignoredLabels).Similar
+}
+
+// firstLabelMismatchAcross is compareNodeLabelsEqual's detail-returning
+// counterpart: it reports the first non-ignored label, in sorted order,
+// that is missing from, or has a different value on, one of nodes.
+func firstLabelMismatchAcross(nodes []*schedulernodeinfo.NodeInfo, ignoredLabels map[string]bool) SimilarityResult {
+	labels := make(map[string][]string)
+	for _, node := range nodes {
+		for label, value := range node.Node().ObjectMeta.Labels {
+			if ignoredLabels[label] {
+				continue
+			}
+			labels[label] = append(labels[label], value)
+		}
+	}
+
+	for _, label := range sortedLabelKeys(labels) {
+		values := labels[label]
+		if len(values) != len(nodes) {
+			return SimilarityResult{Reason: MismatchReasonLabel, Label: label, ValueA: values[0]}
+		}
+		for _, v := range values[1:] {
+			if v != values[0] {
+				return SimilarityResult{Reason: MismatchReasonLabel, Label: label, ValueA: values[0], ValueB: v}
+			}
+		}
+	}
+
+	return similarResult
+}
+
+// ParseResourceModelConfigBounds turns a resource-name -> ascending bound
+// list map (as decoded from an operator-supplied config file) into a
+// ResourceModelConfig, validating that every list is non-empty and
+// strictly ascending so resourceModelBucket's binary search is well
+// defined.
+func ParseResourceModelConfigBounds(raw map[apiv1.ResourceName][]resource.Quantity) (ResourceModelConfig, error) {
+	for res, bounds := range raw {
+		if len(bounds) == 0 {
+			return ResourceModelConfig{}, fmt.Errorf("resource model bounds for %q must not be empty", res)
+		}
+		for i := 1; i < len(bounds); i++ {
+			if bounds[i-1].Cmp(bounds[i]) >= 0 {
+				return ResourceModelConfig{}, fmt.Errorf("resource model bounds for %q must be strictly ascending, got %s then %s", res, bounds[i-1].String(), bounds[i].String())
+			}
+		}
+	}
+
+	return ResourceModelConfig{Bounds: raw}, nil
+}
+
+// NewResourceModelNodeGroupSetProcessor returns a NodeGroupSetProcessor
+// that groups node groups using IsNodeInfoSimilarByResourceModel, as a
+// selectable alternative to NewNodeGroupSetProcessor's numeric-tolerance
+// comparator.
+func NewResourceModelNodeGroupSetProcessor(config ResourceModelConfig, ignoredLabels map[string]bool) NodeGroupSetProcessor {
+	return &BalancingNodeGroupSetProcessor{
+		Comparator: func(n1, n2 *schedulernodeinfo.NodeInfo) bool {
+			return IsNodeInfoSimilarByResourceModel(n1, n2, config, ignoredLabels)
+		},
+		ResultComparator: func(n1, n2 *schedulernodeinfo.NodeInfo) SimilarityResult {
+			return IsNodeInfoSimilarByResourceModelResult(n1, n2, config, ignoredLabels)
+		},
+	}
+}
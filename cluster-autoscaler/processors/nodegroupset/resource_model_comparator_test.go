@@ -0,0 +1,65 @@
+package nodegroupset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestResourceModelBucketBoundaryRounding(t *testing.T) {
+	config := NewDefaultResourceModelConfig()
+	cpuBounds := config.Bounds[apiv1.ResourceCPU]
+
+	// A node with exactly 2 cores sits at the 2^1 boundary: it belongs to
+	// model 1, not model 2.
+	twoCores := buildBasicNodeInfo("two-cores", "amd64", 2, 4096)
+	assert.Equal(t, 1, resourceModelBucket(twoCores.Node().Status.Capacity[apiv1.ResourceCPU], cpuBounds))
+
+	// One core over the boundary rolls into the next model.
+	threeCores := buildBasicNodeInfo("three-cores", "amd64", 3, 4096)
+	assert.Equal(t, 2, resourceModelBucket(threeCores.Node().Status.Capacity[apiv1.ResourceCPU], cpuBounds))
+
+	// One core under the boundary stays in the previous model.
+	oneCore := buildBasicNodeInfo("one-core", "amd64", 1, 4096)
+	assert.Equal(t, 0, resourceModelBucket(oneCore.Node().Status.Capacity[apiv1.ResourceCPU], cpuBounds))
+}
+
+func TestIsNodeInfoSimilarByResourceModel(t *testing.T) {
+	config := NewDefaultResourceModelConfig()
+	ignoredLabels := DefaultNodeInfoComparatorConfig().IgnoredLabels
+
+	// m5.2xlarge vs m5a.2xlarge: same (8 core, 32GiB) tier, with a 128Mi
+	// memory capacity drift that the numeric-tolerance comparator's default
+	// 128Ki threshold would reject.
+	m5 := buildBasicNodeInfo("m5-2xlarge", "amd64", 8, 32768)
+	m5a := buildBasicNodeInfo("m5a-2xlarge", "amd64", 8, 32640)
+	assert.True(t, IsNodeInfoSimilarByResourceModel(m5, m5a, config, ignoredLabels))
+
+	// A node one tier up in CPU should not be considered similar, even
+	// though memory is identical.
+	biggerCPU := buildBasicNodeInfo("bigger-cpu", "amd64", 16, 32768)
+	assert.False(t, IsNodeInfoSimilarByResourceModel(m5, biggerCPU, config, ignoredLabels))
+}
+
+// TestIsNodeInfoSimilarByResourceModelResultIsDeterministic verifies that
+// when a node pair differs in more than one resource's model bucket, the
+// Resource reported by IsNodeInfoSimilarByResourceModelResult is the same
+// on every call - cpu sorts before memory - rather than depending on Go's
+// randomized map iteration order over config.Bounds.
+func TestIsNodeInfoSimilarByResourceModelResultIsDeterministic(t *testing.T) {
+	config := NewDefaultResourceModelConfig()
+	ignoredLabels := DefaultNodeInfoComparatorConfig().IgnoredLabels
+
+	// Differs in both cpu (8 vs 16 cores) and memory (32GiB vs 64GiB)
+	// bucket, so a map-order-dependent implementation could report either.
+	small := buildBasicNodeInfo("small", "amd64", 8, 32768)
+	big := buildBasicNodeInfo("big", "amd64", 16, 65536)
+
+	for i := 0; i < 10; i++ {
+		result := IsNodeInfoSimilarByResourceModelResult(small, big, config, ignoredLabels)
+		assert.False(t, result.Similar)
+		assert.Equal(t, MismatchReasonModelBucket, result.Reason)
+		assert.Equal(t, apiv1.ResourceCPU, result.Resource)
+	}
+}